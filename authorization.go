@@ -1,13 +1,21 @@
 package crusch
 
 import (
+	"context"
 	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"strconv"
+	"sync"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/weavc/crusch/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Authorizer implements a GetHeader() method which
@@ -26,6 +34,59 @@ func (a AuthorizerFunc) GetHeader() (string, error) {
 	return a()
 }
 
+// ContextAuthorizer is an Authorizer which can also produce its header using a context
+// Authorizers that make requests of their own (i.e. InstallationAuth) should implement this
+// so the request can be cancelled along with the request it is authorizing
+type ContextAuthorizer interface {
+	Authorizer
+	GetHeaderContext(ctx context.Context) (string, error)
+}
+
+// ContextAuthorizerFunc is a wrapper for the ContextAuthorizer interface
+type ContextAuthorizerFunc func(ctx context.Context) (string, error)
+
+// GetHeader wraps ContextAuthorizerFunc using a background context, implementing the Authorizer interface
+func (a ContextAuthorizerFunc) GetHeader() (string, error) {
+	return a(context.Background())
+}
+
+// GetHeaderContext wraps ContextAuthorizerFunc, implementing the ContextAuthorizer interface
+func (a ContextAuthorizerFunc) GetHeaderContext(ctx context.Context) (string, error) {
+	return a(ctx)
+}
+
+// getHeader gets the authorization header from authorizer
+// GetHeaderContext is used when the authorizer implements ContextAuthorizer
+func getHeader(ctx context.Context, authorizer Authorizer) (string, error) {
+	if a, ok := authorizer.(ContextAuthorizer); ok {
+		return a.GetHeaderContext(ctx)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	return authorizer.GetHeader()
+}
+
+// Identifier can be implemented by authorizers to provide a stable identity
+// The identity is used by clients to key state they keep per authorizer, such as rate limits and cached responses
+// Rate limits are not tracked and responses are not cached for authorizers that don't implement it
+// it should be the same for authorizers acting as the same Github user, application or installation
+type Identifier interface {
+	Identity() string
+}
+
+// identity returns the identity of the given authorizer
+// ok is false for authorizers not implementing Identifier, no state is kept for them
+// as their values can't tell apart authorizers acting as different users, i.e. closures over different tokens
+func identity(authorizer Authorizer) (string, bool) {
+	if i, ok := authorizer.(Identifier); ok {
+		return i.Identity(), true
+	}
+	return "", false
+}
+
 // NewApplicationAuth generates and returns a new ApplicationAuth struct using given values
 func NewApplicationAuth(applicationID int64, key *rsa.PrivateKey) (*ApplicationAuth, error) {
 	a := &ApplicationAuth{
@@ -48,6 +109,18 @@ func NewInstallationAuth(applicationID int64, installationID int64, key *rsa.Pri
 	return a, nil
 }
 
+// NewInstallationAuth creates an InstallationAuth that requests its access tokens from c
+// This is required when using a Github Enterprise Server client
+func (c *Client) NewInstallationAuth(applicationID int64, installationID int64, key *rsa.PrivateKey) (*InstallationAuth, error) {
+	a, err := NewInstallationAuth(applicationID, installationID, key)
+	if err != nil {
+		return nil, err
+	}
+
+	a.Client = c
+	return a, nil
+}
+
 // NewOAuth generates and returns an OAuth authorizer that uses given values
 func NewOAuth(token string) (*OAuth, error) {
 	a := &OAuth{Token: token}
@@ -67,13 +140,19 @@ func (a *ApplicationAuth) Dispose() {
 	a.Key = nil
 }
 
+// Identity to implement Identifier
+func (a *ApplicationAuth) Identity() string {
+	return fmt.Sprintf("application/%d", a.ApplicationID)
+}
+
 // GetHeader to implement Authorizer
 // GetHeader generates a new JWT token using the ApplicationID and PEM from Github
 // This header is used for authenticating a Github application against Githubs api
 func (a *ApplicationAuth) GetHeader() (string, error) {
 	now := time.Now()
 	claims := &jwt.StandardClaims{
-		IssuedAt:  now.Add(time.Minute - 1).Unix(),
+		// issued in the past to allow for clock drift between us and Github
+		IssuedAt:  now.Add(-time.Minute).Unix(),
 		ExpiresAt: now.Add(time.Minute * 4).Unix(),
 		Issuer:    strconv.FormatInt(a.ApplicationID, 10),
 	}
@@ -98,11 +177,30 @@ type InstallationAuth struct {
 	InstallationID int64
 	Key            *rsa.PrivateKey
 	Client         *Client
+	// Logger logs access token requests, the Clients logger is used if nil
+	Logger *slog.Logger
+	// Metrics records access token requests, the Clients metrics are used if nil
+	Metrics Metrics
 	LastUsed
+
+	// mu guards LastUsed and refresh
+	mu sync.Mutex
+	// refresh is the access token request in flight, nil if there is none
+	refresh *tokenRefresh
+}
+
+// tokenRefresh is an access token request shared by concurrent calls to GetHeaderContext
+// done is closed once header and err have been set
+type tokenRefresh struct {
+	done   chan struct{}
+	header string
+	err    error
 }
 
 // Dispose of values in InstallationAuth
 func (a *InstallationAuth) Dispose() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	a.ApplicationID = 0
 	a.InstallationID = 0
 	a.Key = nil
@@ -110,6 +208,12 @@ func (a *InstallationAuth) Dispose() {
 	a.header = ""
 	a.validUntil = 0
 	a.time = 0
+	a.refresh = nil
+}
+
+// Identity to implement Identifier
+func (a *InstallationAuth) Identity() string {
+	return fmt.Sprintf("installation/%d", a.InstallationID)
 }
 
 // GetHeader to implement Authorizer
@@ -119,31 +223,155 @@ func (a *InstallationAuth) Dispose() {
 // If InstallationAuth has already generated an auth token and it is still valid, this will be used instead
 // https://developer.github.com/v3/apps/#create-a-new-installation-token
 func (a *InstallationAuth) GetHeader() (string, error) {
+	return a.GetHeaderContext(context.Background())
+}
+
+// GetHeaderContext to implement ContextAuthorizer
+// This is the same as GetHeader, but the access token request is made using ctx
+// It is safe for concurrent use, concurrent calls share a single access token request,
+// returning early if their own ctx is done while waiting for it
+func (a *InstallationAuth) GetHeaderContext(ctx context.Context) (string, error) {
+	for {
+		a.mu.Lock()
+		if time.Now().Unix() <= a.validUntil && a.header != "" {
+			header := a.header
+			a.mu.Unlock()
+			return header, nil
+		}
+
+		r := a.refresh
+		if r == nil {
+			r = &tokenRefresh{done: make(chan struct{})}
+			a.refresh = r
+			t := a.newTokenRequest()
+			a.mu.Unlock()
+
+			return a.refreshToken(ctx, r, t)
+		}
+		a.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-r.done:
+		}
+
+		// the request failed because the context of the call that made it is done, make our own
+		if r.err != nil && (errors.Is(r.err, context.Canceled) || errors.Is(r.err, context.DeadlineExceeded)) && ctx.Err() == nil {
+			continue
+		}
+
+		return r.header, r.err
+	}
+}
 
-	if time.Now().Unix() <= a.validUntil && a.header != "" {
-		return a.header, nil
+// refreshToken makes the access token request t, storing and sharing its result through r
+func (a *InstallationAuth) refreshToken(ctx context.Context, r *tokenRefresh, t *tokenRequest) (string, error) {
+	token, err := t.do(ctx)
+	if err == nil {
+		r.header = fmt.Sprintf("token %s", token)
 	}
+	r.err = err
+
+	a.mu.Lock()
+	// the auth may have been disposed of while the request was in flight
+	if a.refresh == r {
+		a.refresh = nil
+		if err == nil {
+			a.header = r.header
+			a.validUntil = time.Now().Add((time.Hour - time.Minute)).Unix()
+			a.time = time.Now().Unix()
+		}
+	}
+	a.mu.Unlock()
 
-	var client *Client
+	close(r.done)
+	return r.header, r.err
+}
+
+// tokenRequest is a snapshot of the values needed to request an access token, so the request
+// can be made without holding mu
+type tokenRequest struct {
+	applicationID  int64
+	installationID int64
+	key            *rsa.PrivateKey
+	client         *Client
+	logger         *slog.Logger
+	metrics        Metrics
+}
+
+// newTokenRequest creates a tokenRequest from a, mu must be held
+func (a *InstallationAuth) newTokenRequest() *tokenRequest {
+	t := &tokenRequest{
+		applicationID:  a.ApplicationID,
+		installationID: a.InstallationID,
+		key:            a.Key,
+		client:         a.Client,
+		logger:         a.Logger,
+		metrics:        a.Metrics,
+	}
 
 	// use client attached to auth, or the default GithubClient
-	if a.Client != nil {
-		client = a.Client
-	} else {
-		client = GithubClient
+	if t.client == nil {
+		t.client = GithubClient
+	}
+
+	if t.logger == nil {
+		t.logger = t.client.getLogger()
+	}
+
+	if t.metrics == nil {
+		t.metrics = t.client.getMetrics()
 	}
 
-	auth, err := NewApplicationAuth(a.ApplicationID, a.Key)
+	return t
+}
+
+// do requests an access token, recording it in the logs, metrics and traces
+func (t *tokenRequest) do(ctx context.Context) (string, error) {
+	ctx, span := t.client.startSpan(ctx, "github installation token", trace.SpanKindInternal,
+		attribute.Int64("github.application_id", t.applicationID),
+		attribute.Int64("github.installation_id", t.installationID))
+	token, err := t.request(ctx)
+	recordSpanError(span, err)
+	span.End()
+
+	t.metrics.ObserveTokenRefresh(fmt.Sprintf("installation/%d", t.installationID), err != nil)
+	if err != nil {
+		if t.logger != nil {
+			t.logger.LogAttrs(ctx, slog.LevelError, "github installation token request failed",
+				slog.Int64("application_id", t.applicationID),
+				slog.Int64("installation_id", t.installationID),
+				slog.String("error", internal.Redact(err.Error())))
+		}
+		return "", err
+	}
+
+	if t.logger != nil {
+		t.logger.LogAttrs(ctx, slog.LevelInfo, "github installation token refreshed",
+			slog.Int64("application_id", t.applicationID),
+			slog.Int64("installation_id", t.installationID),
+			slog.Time("valid_until", time.Now().Add(time.Hour-time.Minute).Truncate(time.Second)))
+	}
+
+	return token, nil
+}
+
+// request requests a new installation access token from the client
+func (t *tokenRequest) request(ctx context.Context) (string, error) {
+	auth, err := NewApplicationAuth(t.applicationID, t.key)
 	if err != nil {
 		return "", fmt.Errorf("unable to create application authorizer: %v", err)
 	}
 
 	var v map[string]interface{}
-	res, err := client.Post(
+	res, err := t.client.PostContext(
+		ctx,
 		auth,
-		fmt.Sprintf("app/installations/%d/access_tokens", a.InstallationID),
+		"app/installations/{installation_id}/access_tokens",
 		nil,
 		&v,
+		WithPathParams(map[string]string{"installation_id": strconv.FormatInt(t.installationID, 10)}),
 	)
 
 	if err != nil {
@@ -154,16 +382,12 @@ func (a *InstallationAuth) GetHeader() (string, error) {
 		return "", fmt.Errorf("%d error when trying to create access token", res.StatusCode)
 	}
 
-	t, ok := v["token"].(string)
+	tok, ok := v["token"].(string)
 	if !ok {
 		return "", fmt.Errorf("error mapping token value")
 	}
 
-	a.header = fmt.Sprintf("token %s", t)
-	a.validUntil = time.Now().Add((time.Hour - time.Minute)).Unix()
-	a.time = time.Now().Unix()
-
-	return a.header, nil
+	return tok, nil
 }
 
 // OAuth authorizor for Githubs v3 API
@@ -177,6 +401,12 @@ func (a *OAuth) Dispose() {
 	a.Token = ""
 }
 
+// Identity to implement Identifier
+// The token is hashed so it isn't exposed through the identity
+func (a *OAuth) Identity() string {
+	return fmt.Sprintf("oauth/%x", sha256.Sum256([]byte(a.Token)))
+}
+
 // GetHeader to implement Authorizer
 // returns the Authorization header required for oauth authentication
 func (a *OAuth) GetHeader() (string, error) {
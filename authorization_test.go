@@ -1,9 +1,15 @@
 package crusch
 
 import (
+	"context"
 	"crypto/rsa"
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
 )
 
 func TestRSAPrivateKeyFromPEMFile(t *testing.T) {
@@ -44,12 +50,29 @@ func TestApplicationAuthorizer(t *testing.T) {
 			auth.ApplicationID, 6000)
 	}
 
-	_, err = auth.GetHeader()
+	header, err := auth.GetHeader()
 	if err != nil {
 		t.Errorf("application auth: unexpected %v", err)
 	}
 
-	// test jwt claims etc
+	claims := &jwt.StandardClaims{}
+	_, err = jwt.ParseWithClaims(strings.TrimPrefix(header, "bearer "), claims, func(*jwt.Token) (interface{}, error) {
+		return &key.PublicKey, nil
+	})
+	if err != nil {
+		t.Errorf("application auth jwt: unexpected %v", err)
+	}
+
+	now := time.Now().Unix()
+	if claims.IssuedAt > now {
+		t.Errorf("application auth jwt: issued at %v want <= %v", claims.IssuedAt, now)
+	}
+	if claims.ExpiresAt <= now || claims.ExpiresAt-claims.IssuedAt > 600 {
+		t.Errorf("application auth jwt: expires at %v issued at %v want within 10 minutes", claims.ExpiresAt, claims.IssuedAt)
+	}
+	if claims.Issuer != "6000" {
+		t.Errorf("application auth jwt: issuer %v want %v", claims.Issuer, "6000")
+	}
 }
 
 func TestOAuthAuthorizer(t *testing.T) {
@@ -129,6 +152,142 @@ func TestInstallationAuthorizer(t *testing.T) {
 	}
 }
 
+func TestInstallationAuthorizerContext(t *testing.T) {
+	type tokenResponse struct {
+		Token string `json:"token"`
+	}
+
+	auth, _ := NewInstallationAuth(123456, 678903, getKey())
+	auth.Client = setupClient(tokenResponse{Token: "testtokenstring"})
+	defer auth.Dispose()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := auth.GetHeaderContext(ctx)
+	if err == nil {
+		t.Errorf("installation auth, cancelled context: unexpected nil error")
+	}
+
+	h, err := auth.GetHeaderContext(context.Background())
+	if err != nil {
+		t.Errorf("installation auth context: unexpected %v", err)
+	}
+
+	if h != "token testtokenstring" {
+		t.Errorf("installation auth context: returned %v want %s", h, "token testtokenstring")
+	}
+}
+
+func TestInstallationAuthorizerConcurrent(t *testing.T) {
+	type tokenResponse struct {
+		Token string `json:"token"`
+	}
+
+	rt := &fakeTransport{body: tokenResponse{Token: "testtokenstring"}}
+	client := setupFakeClient(rt)
+
+	auth, _ := client.NewInstallationAuth(123456, 678903, getKey())
+	defer auth.Dispose()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h, err := auth.GetHeader()
+			if err != nil || h != "token testtokenstring" {
+				t.Errorf("installation auth concurrent: returned %v, %v", h, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := rt.Calls(); n != 1 {
+		t.Errorf("installation auth concurrent: requested %d tokens want 1", n)
+	}
+}
+
+func TestInstallationAuthorizerWait(t *testing.T) {
+	type tokenResponse struct {
+		Token string `json:"token"`
+	}
+
+	rt := &fakeTransport{body: tokenResponse{Token: "testtokenstring"}, delay: 300 * time.Millisecond}
+	client := setupFakeClient(rt)
+	auth, _ := client.NewInstallationAuth(123456, 678903, getKey())
+	defer auth.Dispose()
+
+	refreshed := make(chan error, 1)
+	go func() {
+		_, err := auth.GetHeader()
+		refreshed <- err
+	}()
+	for rt.Calls() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := auth.GetHeaderContext(ctx)
+	if err != context.DeadlineExceeded || time.Since(start) > 200*time.Millisecond {
+		t.Errorf("installation auth wait: returned %v after %v want %v", err, time.Since(start), context.DeadlineExceeded)
+	}
+
+	if err := <-refreshed; err != nil {
+		t.Errorf("installation auth wait, refresh: unexpected %v", err)
+	}
+
+	h, err := auth.GetHeader()
+	if err != nil || h != "token testtokenstring" || rt.Calls() != 1 {
+		t.Errorf("installation auth wait: returned %v, %v after %d requests", h, err, rt.Calls())
+	}
+}
+
+func TestInstallationAuthorizerWaitCancelled(t *testing.T) {
+	type tokenResponse struct {
+		Token string `json:"token"`
+	}
+
+	rt := &fakeTransport{body: tokenResponse{Token: "testtokenstring"}, delay: 100 * time.Millisecond}
+	client := setupFakeClient(rt)
+	auth, _ := client.NewInstallationAuth(123456, 678903, getKey())
+	defer auth.Dispose()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	go func() {
+		_, err := auth.GetHeaderContext(ctx)
+		cancelled <- err
+	}()
+	for rt.Calls() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	waited := make(chan error, 1)
+	go func() {
+		h, err := auth.GetHeader()
+		if err == nil && h != "token testtokenstring" {
+			err = fmt.Errorf("header %s", h)
+		}
+		waited <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	if err := <-cancelled; err == nil {
+		t.Errorf("installation auth cancelled: unexpected nil error")
+	}
+
+	// the waiting call requests its own token rather than returning the cancelled error
+	if err := <-waited; err != nil || rt.Calls() != 2 {
+		t.Errorf("installation auth cancelled, waiting: returned %v after %d requests", err, rt.Calls())
+	}
+}
+
 func getKey() *rsa.PrivateKey {
 	key, err := RSAPrivateKeyFromPEMFile("random_key.pem")
 	if err != nil {
@@ -0,0 +1,380 @@
+package crusch
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Cache stores responses so they can be revalidated with conditional requests
+// Github does not count 304 Not Modified responses against the rate limit
+// https://docs.github.com/en/rest/overview/resources-in-the-rest-api#conditional-requests
+type Cache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, res *CachedResponse)
+	Delete(key string)
+}
+
+// CachedResponse is a response stored in a Cache
+type CachedResponse struct {
+	ETag         string      `json:"etag"`
+	LastModified string      `json:"last_modified"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+}
+
+// DefaultCacheMaxBytes is the default size limit of MemoryCache and DiskCache, 64MB
+const DefaultCacheMaxBytes = 64 << 20
+
+// SetCache sets the cache used for conditional GET requests, nil disables caching
+// Responses are cached per url, Accept header and authorizer identity
+// Streamed requests and requests binding the raw body (*[]byte, *string or io.Writer) are not cached,
+// so large content fetched with GetMedia or WithStream is never held in memory
+func (c *Client) SetCache(cache Cache) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache = cache
+}
+
+// prepareConditional adds conditional headers to cacheable requests with a cached response
+// returns the cache and key to use for the request, cache is nil if the request should not be cached
+func (c *Client) prepareConditional(authorizer Authorizer, req *http.Request) (Cache, string, *CachedResponse) {
+	c.mu.Lock()
+	cache := c.cache
+	c.mu.Unlock()
+
+	id, ok := identity(authorizer)
+	if cache == nil || !ok || req.Method != http.MethodGet {
+		return nil, "", nil
+	}
+
+	key := fmt.Sprintf("%s %s %s", id, req.Header.Get("Accept"), req.URL.String())
+	cached, ok := cache.Get(key)
+	if !ok {
+		return cache, key, nil
+	}
+
+	if cached.ETag != "" && req.Header.Get("If-None-Match") == "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+	if cached.LastModified != "" && req.Header.Get("If-Modified-Since") == "" {
+		req.Header.Set("If-Modified-Since", cached.LastModified)
+	}
+
+	return cache, key, cached
+}
+
+// applyConditional replaces 304 responses with the cached response, and caches new responses
+func applyConditional(cache Cache, key string, cached *CachedResponse, res *http.Response) error {
+	if res.StatusCode == http.StatusNotModified && cached != nil {
+		res.Body.Close()
+
+		for k, v := range cached.Header {
+			if _, ok := res.Header[k]; !ok {
+				res.Header[k] = v
+			}
+		}
+		res.Header.Set("X-From-Cache", "1")
+		res.Status = "200 OK"
+		res.StatusCode = http.StatusOK
+		res.ContentLength = int64(len(cached.Body))
+		res.Body = ioutil.NopCloser(bytes.NewReader(cached.Body))
+		return nil
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	etag, modified := res.Header.Get("ETag"), res.Header.Get("Last-Modified")
+	if etag == "" && modified == "" {
+		return nil
+	}
+
+	b, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	cache.Set(key, &CachedResponse{
+		ETag:         etag,
+		LastModified: modified,
+		Header:       res.Header.Clone(),
+		Body:         b,
+	})
+
+	return nil
+}
+
+// size approximates the bytes used by the response
+func (r *CachedResponse) size() int64 {
+	n := int64(len(r.ETag) + len(r.LastModified) + len(r.Body))
+	for k, values := range r.Header {
+		n += int64(len(k))
+		for _, v := range values {
+			n += int64(len(v))
+		}
+	}
+	return n
+}
+
+// MemoryCache is an in memory least recently used Cache
+// It is limited by both the number of responses and their total size
+type MemoryCache struct {
+	size     int
+	maxBytes int64
+	bytes    int64
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	order    *list.List
+}
+
+type memoryEntry struct {
+	key  string
+	res  *CachedResponse
+	size int64
+}
+
+// NewMemoryCache creates a MemoryCache holding up to size responses, and up to DefaultCacheMaxBytes
+func NewMemoryCache(size int) *MemoryCache {
+	return &MemoryCache{
+		size:     size,
+		maxBytes: DefaultCacheMaxBytes,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+// SetMaxBytes sets the total size of the responses the cache holds, <= 0 removes the limit
+// Least recently used responses are evicted if the cache is over the new limit
+func (m *MemoryCache) SetMaxBytes(maxBytes int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxBytes = maxBytes
+	m.evict()
+}
+
+// Get to implement Cache
+func (m *MemoryCache) Get(key string) (*CachedResponse, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	m.order.MoveToFront(e)
+	return e.Value.(*memoryEntry).res, true
+}
+
+// Set to implement Cache
+// Least recently used responses are evicted if the cache is full
+// Responses larger than the size limit of the cache are not stored
+func (m *MemoryCache) Set(key string, res *CachedResponse) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(key)
+
+	size := res.size() + int64(len(key))
+	if m.maxBytes > 0 && size > m.maxBytes {
+		return
+	}
+
+	m.entries[key] = m.order.PushFront(&memoryEntry{key: key, res: res, size: size})
+	m.bytes += size
+	m.evict()
+}
+
+// Delete to implement Cache
+func (m *MemoryCache) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(key)
+}
+
+// remove removes key from the cache, mu must be held
+func (m *MemoryCache) remove(key string) {
+	if e, ok := m.entries[key]; ok {
+		m.order.Remove(e)
+		delete(m.entries, key)
+		m.bytes -= e.Value.(*memoryEntry).size
+	}
+}
+
+// evict removes least recently used responses until the cache is within its limits, mu must be held
+func (m *MemoryCache) evict() {
+	for m.order.Len() > 0 && (m.size > 0 && m.order.Len() > m.size || m.maxBytes > 0 && m.bytes > m.maxBytes) {
+		m.remove(m.order.Back().Value.(*memoryEntry).key)
+	}
+}
+
+// DiskCache is a Cache storing responses as files inside a directory
+// Keys are hashed to create the file names
+// Once the files are over the size limit of the cache the least recently used are removed,
+// using their modification times which are updated when they are read
+type DiskCache struct {
+	dir string
+
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+}
+
+// NewDiskCache creates a DiskCache using dir, which is created if it does not exist
+// The cache holds up to DefaultCacheMaxBytes, including any files already in dir
+func NewDiskCache(dir string) (*DiskCache, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %v", err)
+	}
+
+	d := &DiskCache{dir: dir, maxBytes: DefaultCacheMaxBytes}
+	d.evict()
+	return d, nil
+}
+
+// SetMaxBytes sets the total size of the files the cache holds, <= 0 removes the limit
+// Least recently used files are removed if the cache is over the new limit
+func (d *DiskCache) SetMaxBytes(maxBytes int64) {
+	d.mu.Lock()
+	d.maxBytes = maxBytes
+	d.mu.Unlock()
+	d.evict()
+}
+
+// Get to implement Cache
+// Responses which cannot be read are treated as missing
+func (d *DiskCache) Get(key string) (*CachedResponse, bool) {
+	path := d.path(key)
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	res := &CachedResponse{}
+	err = json.Unmarshal(b, res)
+	if err != nil {
+		return nil, false
+	}
+
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	return res, true
+}
+
+// Set to implement Cache
+// The response is written to a temporary file first so partially written responses are never read
+// Responses larger than the size limit of the cache are not stored
+func (d *DiskCache) Set(key string, res *CachedResponse) {
+	b, err := json.Marshal(res)
+	if err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	maxBytes := d.maxBytes
+	d.mu.Unlock()
+	if maxBytes > 0 && int64(len(b)) > maxBytes {
+		d.Delete(key)
+		return
+	}
+
+	f, err := ioutil.TempFile(d.dir, "tmp-")
+	if err != nil {
+		return
+	}
+
+	_, err = f.Write(b)
+	f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+
+	path := d.path(key)
+	var replaced int64
+	if info, err := os.Stat(path); err == nil {
+		replaced = info.Size()
+	}
+
+	err = os.Rename(f.Name(), path)
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+
+	d.mu.Lock()
+	d.bytes += int64(len(b)) - replaced
+	over := d.maxBytes > 0 && d.bytes > d.maxBytes
+	d.mu.Unlock()
+
+	if over {
+		d.evict()
+	}
+}
+
+// Delete to implement Cache
+func (d *DiskCache) Delete(key string) {
+	path := d.path(key)
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	if os.Remove(path) == nil {
+		d.mu.Lock()
+		d.bytes -= info.Size()
+		d.mu.Unlock()
+	}
+}
+
+// evict recalculates the size of the cache from the files in its directory,
+// removing the least recently used until it is within its size limit
+func (d *DiskCache) evict() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entries, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		return
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime().Before(entries[j].ModTime())
+	})
+
+	var total int64
+	for _, e := range entries {
+		total += e.Size()
+	}
+
+	for _, e := range entries {
+		if d.maxBytes <= 0 || total <= d.maxBytes {
+			break
+		}
+		if e.IsDir() || os.Remove(filepath.Join(d.dir, e.Name())) != nil {
+			continue
+		}
+		total -= e.Size()
+	}
+
+	d.bytes = total
+}
+
+func (d *DiskCache) path(key string) string {
+	return filepath.Join(d.dir, fmt.Sprintf("%x", sha256.Sum256([]byte(key))))
+}
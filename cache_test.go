@@ -0,0 +1,197 @@
+package crusch
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConditionalCache(t *testing.T) {
+	etag, body, notModified := `"abc"`, `{"weavc": "crusch", "one": "1"}`, 0
+	client := setupFakeClient(&fakeTransport{handler: func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Etag", etag)
+		if req.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}})
+	client.SetCache(NewMemoryCache(10))
+
+	auth := setupAuth()
+	want := m{Weavc: "crusch", One: "1"}
+
+	var v m
+	res, err := client.Get(auth, "test/uri", nil, &v)
+	if err != nil || !reflect.DeepEqual(v, want) {
+		t.Fatalf("first request: returned %v, %v", v, err)
+	}
+
+	if res.Header.Get("X-From-Cache") != "" {
+		t.Errorf("first request: unexpectedly served from cache")
+	}
+
+	v = m{}
+	res, err = client.Get(auth, "test/uri", nil, &v)
+	if err != nil || !reflect.DeepEqual(v, want) {
+		t.Fatalf("cached request: returned %v, %v", v, err)
+	}
+
+	if notModified != 1 || res.StatusCode != 200 || res.Header.Get("X-From-Cache") != "1" {
+		t.Errorf("cached request: %d not modified responses, status %d", notModified, res.StatusCode)
+	}
+
+	if res.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("cached request: cached headers were not restored")
+	}
+
+	other, _ := NewOAuth("anothertoken")
+	_, err = client.Get(other, "test/uri", nil, &v)
+	if err != nil || notModified != 1 {
+		t.Errorf("other authorizer: used cached response of another authorizer")
+	}
+
+	etag = `"def"`
+	body = `{"weavc": "changed"}`
+	v = m{}
+	_, err = client.Get(auth, "test/uri", nil, &v)
+	if err != nil || v.Weavc != "changed" {
+		t.Errorf("modified: returned %v, %v", v, err)
+	}
+}
+
+func TestConditionalCacheSkipped(t *testing.T) {
+	rt := &fakeTransport{header: http.Header{"Etag": {`"abc"`}}, body: "raw content"}
+	client := setupFakeClient(rt)
+	cache := NewMemoryCache(10)
+	client.SetCache(cache)
+
+	var raw []byte
+	var buf bytes.Buffer
+	requests := []func() error{
+		func() error { _, err := client.GetMedia(setupAuth(), "test/uri", nil, MediaTypeRaw, &raw); return err },
+		func() error { _, err := client.GetMedia(setupAuth(), "test/uri", nil, MediaTypeRaw, &buf); return err },
+		func() error {
+			res, err := client.Get(setupAuth(), "test/uri", nil, nil, WithStream())
+			if err == nil {
+				res.Body.Close()
+			}
+			return err
+		},
+	}
+
+	for i, request := range requests {
+		for j := 0; j < 2; j++ {
+			err := request()
+			if err != nil || rt.Request().Header.Get("If-None-Match") != "" {
+				t.Errorf("uncached request %d: returned %v, sent %v", i, err, rt.Request().Header)
+			}
+		}
+	}
+
+	if n := cache.order.Len(); n != 0 {
+		t.Errorf("uncached requests: cached %d responses", n)
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	c := NewMemoryCache(2)
+
+	c.Set("a", &CachedResponse{ETag: "a"})
+	c.Set("b", &CachedResponse{ETag: "b"})
+	c.Get("a")
+	c.Set("c", &CachedResponse{ETag: "c"})
+
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("memory cache: least recently used entry was not evicted")
+	}
+
+	if r, ok := c.Get("a"); !ok || r.ETag != "a" {
+		t.Errorf("memory cache: returned %v, %v", r, ok)
+	}
+
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("memory cache: deleted entry returned")
+	}
+
+	c = NewMemoryCache(0)
+	c.SetMaxBytes(250)
+	for _, key := range []string{"a", "b", "c"} {
+		c.Set(key, &CachedResponse{Body: make([]byte, 100)})
+	}
+
+	if _, ok := c.Get("a"); ok || c.bytes > 250 {
+		t.Errorf("memory cache bytes: holding %d bytes, least recently used entry was not evicted", c.bytes)
+	}
+
+	c.Set("large", &CachedResponse{Body: make([]byte, 300)})
+	if _, ok := c.Get("large"); ok {
+		t.Errorf("memory cache bytes: stored response larger than the cache")
+	}
+
+	c.SetMaxBytes(150)
+	if _, ok := c.Get("b"); ok || c.bytes > 150 {
+		t.Errorf("memory cache bytes: holding %d bytes after lowering the limit", c.bytes)
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	c, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("disk cache: unexpected %v", err)
+	}
+
+	want := &CachedResponse{
+		ETag:   `"abc"`,
+		Header: http.Header{"Content-Type": {"application/json"}},
+		Body:   []byte(`{"weavc": "crusch"}`),
+	}
+	c.Set("installation/1 https://api.github.com/repos", want)
+
+	r, ok := c.Get("installation/1 https://api.github.com/repos")
+	if !ok || !reflect.DeepEqual(r, want) {
+		t.Errorf("disk cache: returned %v want %v", r, want)
+	}
+
+	c.Delete("installation/1 https://api.github.com/repos")
+	if _, ok := c.Get("installation/1 https://api.github.com/repos"); ok {
+		t.Errorf("disk cache: deleted entry returned")
+	}
+
+	// room for three responses
+	entry, _ := json.Marshal(&CachedResponse{Body: make([]byte, 100)})
+	limit := int64(len(entry)*3 + len(entry)/2)
+	c.SetMaxBytes(limit)
+	for i, key := range []string{"a", "b", "c"} {
+		c.Set(key, &CachedResponse{Body: make([]byte, 100)})
+		// modification times are used to find the least recently used files
+		past := time.Now().Add(time.Duration(i-10) * time.Minute)
+		os.Chtimes(c.path(key), past, past)
+	}
+	c.Get("a")
+	c.Set("d", &CachedResponse{Body: make([]byte, 100)})
+
+	if _, ok := c.Get("b"); ok || c.bytes > limit {
+		t.Errorf("disk cache bytes: holding %d bytes, least recently used file was not removed", c.bytes)
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Errorf("disk cache bytes: recently used file was removed")
+	}
+
+	c.Set("large", &CachedResponse{Body: make([]byte, 1000)})
+	if _, ok := c.Get("large"); ok {
+		t.Errorf("disk cache bytes: stored response larger than the cache")
+	}
+
+	reopened, _ := NewDiskCache(c.dir)
+	if reopened.bytes != c.bytes {
+		t.Errorf("disk cache bytes: reopened cache holding %d bytes want %d", reopened.bytes, c.bytes)
+	}
+}
@@ -1,22 +1,46 @@
 package crusch
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/weavc/crusch/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Client is used to process requests to and from Githubs v3 api
-// URL and protocols can be changed
+// Clients are safe for concurrent use, their configuration can be changed using the Set and With methods
+// URL and Protocol must not be changed once the client is in use, use Clone or WithURL to derive a client instead
 type Client struct {
 	URL      string
 	Protocol string
-	Headers  []header
-	client   *http.Client
+	// headers are replaced rather than modified by AddHeader and RemoveHeader, slices held by in flight requests are never changed
+	headers []header
+
+	// mu guards the configuration and rate limit state below
+	mu               sync.Mutex
+	client           *http.Client
+	rateWait         bool
+	rates            map[string]Rate
+	secondaryRetries int
+	secondaryMaxWait time.Duration
+	retryPolicy      *RetryPolicy
+	cache            Cache
+	middleware       []Middleware
+	logger           *slog.Logger
+	metrics          Metrics
+	tracer           trace.Tracer
 }
 
 type header struct {
@@ -40,180 +64,543 @@ func NewGithubClient(url string, protocol string) *Client {
 	return c
 }
 
+// NewEnterpriseClient creates a client for a Github Enterprise Server instance
+// baseURL is the url of the instance i.e. https://github.example.com
+// The v3 API is served from /api/v3, uploads from /api/uploads and GraphQL from /api/graphql
+// The client can be used by InstallationAuth by setting its Client, or with Client.NewInstallationAuth
+func NewEnterpriseClient(baseURL string) (*Client, error) {
+	if !strings.Contains(baseURL, "://") {
+		baseURL = fmt.Sprintf("https://%s", baseURL)
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse base url: %v", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("base url %s has no host", baseURL)
+	}
+
+	path := strings.TrimRight(u.Path, "/")
+	path = strings.TrimSuffix(path, "/api/v3")
+
+	return NewGithubClient(fmt.Sprintf("%s%s/api/v3", u.Host, path), u.Scheme), nil
+}
+
+// WebURL returns the url of the web interface the client's API belongs to, without a trailing slash
+// i.e. https://github.com for api.github.com, which is also where OAuth applications authorize users
+func (c *Client) WebURL() string {
+	u := strings.TrimRight(c.URL, "/")
+	switch {
+	case u == "api.github.com":
+		return fmt.Sprintf("%s://github.com", c.Protocol)
+	case strings.HasSuffix(u, "/api/v3"):
+		return fmt.Sprintf("%s://%s", c.Protocol, strings.TrimSuffix(u, "/api/v3"))
+	default:
+		return fmt.Sprintf("%s://%s", c.Protocol, u)
+	}
+}
+
 // SetHTTPClient allows the http.Client on GithubClient to be changed
 // http.DefaultClient is used by default
 func (c *Client) SetHTTPClient(client *http.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.client = client
 }
 
 // AddHeader adds headers to the array of headers used in the request
 func (c *Client) AddHeader(name string, value string) {
-	c.RemoveHeader(name)
-	h := header{Name: name, Value: value}
-	c.Headers = append(c.Headers, h)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.headers = append(withoutHeader(c.headers, name), header{Name: name, Value: value})
 }
 
 // RemoveHeader headers to the array of headers used in the request
 func (c *Client) RemoveHeader(name string) {
-	for i, h := range c.Headers {
-		if h.Name == name {
-			c.Headers = append(c.Headers[:i], c.Headers[i+1:]...)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.headers = withoutHeader(c.headers, name)
+}
+
+// Headers returns a copy of the headers added to every request
+func (c *Client) Headers() http.Header {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	h := make(http.Header, len(c.headers))
+	for _, v := range c.headers {
+		h.Add(v.Name, v.Value)
+	}
+	return h
+}
+
+// withoutHeader returns a copy of headers without the named header
+// a copy is always made so slices held by in flight requests are never modified
+func withoutHeader(headers []header, name string) []header {
+	h := make([]header, 0, len(headers)+1)
+	for _, v := range headers {
+		if v.Name != name {
+			h = append(h, v)
 		}
 	}
+	return h
+}
+
+// Clone returns a copy of the client, with the same configuration and known rate limits
+// Changes made to either client afterwards do not affect the other, the http.Client and Cache are shared
+func (c *Client) Clone() *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clone := &Client{
+		URL:              c.URL,
+		Protocol:         c.Protocol,
+		headers:          append([]header(nil), c.headers...),
+		client:           c.client,
+		rateWait:         c.rateWait,
+		secondaryRetries: c.secondaryRetries,
+		secondaryMaxWait: c.secondaryMaxWait,
+		retryPolicy:      c.retryPolicy,
+		cache:            c.cache,
+		middleware:       c.middleware,
+		logger:           c.logger,
+		metrics:          c.metrics,
+		tracer:           c.tracer,
+	}
+
+	if c.rates != nil {
+		clone.rates = make(map[string]Rate, len(c.rates))
+		for k, v := range c.rates {
+			clone.rates[k] = v
+		}
+	}
+
+	return clone
+}
+
+// WithDefaultHeader returns a clone of the client with the given header added to every request
+func (c *Client) WithDefaultHeader(name string, value string) *Client {
+	clone := c.Clone()
+	clone.AddHeader(name, value)
+	return clone
+}
+
+// WithHTTPClient returns a clone of the client using the given http.Client
+func (c *Client) WithHTTPClient(client *http.Client) *Client {
+	clone := c.Clone()
+	clone.SetHTTPClient(client)
+	return clone
+}
+
+// WithURL returns a clone of the client making requests to the given url and protocol
+// Known rate limits are not kept, as they belong to the original url
+func (c *Client) WithURL(url string, protocol string) *Client {
+	clone := c.Clone()
+	clone.URL = url
+	clone.Protocol = protocol
+	clone.rates = nil
+	return clone
+}
+
+// httpClient returns the http.Client used to send requests
+func (c *Client) httpClient() *http.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.client
 }
 
 // Get makes GET requests using the providers information
 // Additional parameters/querystring can be passed through either as a string, struct or left as nil
 // The response body will be bound to v
-func (c *Client) Get(authorizer Authorizer, uri string, params interface{}, v interface{}) (*http.Response, error) {
-	var req *http.Request
+func (c *Client) Get(authorizer Authorizer, uri string, params interface{}, v interface{}, options ...RequestOption) (*Response, error) {
+	return c.GetContext(context.Background(), authorizer, uri, params, v, options...)
+}
 
+// GetContext makes GET requests using the providers information and the given context
+// Additional parameters/querystring can be passed through either as a string, struct or left as nil
+// The response body will be bound to v
+func (c *Client) GetContext(ctx context.Context, authorizer Authorizer, uri string, params interface{}, v interface{}, options ...RequestOption) (*Response, error) {
 	query, err := internal.ParseQuery(params)
 	if err != nil {
 		return nil, err
 	}
 
-	uri = strings.TrimLeft(uri, "/")
-
-	req, err = http.NewRequest(
-		http.MethodGet,
-		fmt.Sprintf("%s://%s/%s?%s", c.Protocol, c.URL, uri, query),
-		nil,
-	)
+	req, err := c.newRequest(ctx, http.MethodGet, uri, query, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
+		return nil, err
 	}
 
-	return c.Do(authorizer, req, v)
+	return c.Do(authorizer, req, v, options...)
 }
 
 // Delete makes DELETE using the providers information
-func (c *Client) Delete(authorizer Authorizer, uri string) (*http.Response, error) {
-	var req *http.Request
-
-	uri = strings.TrimLeft(uri, "/")
+func (c *Client) Delete(authorizer Authorizer, uri string, options ...RequestOption) (*Response, error) {
+	return c.DeleteContext(context.Background(), authorizer, uri, options...)
+}
 
-	req, err := http.NewRequest(
-		http.MethodDelete,
-		fmt.Sprintf("%s://%s/%s", c.Protocol, c.URL, uri),
-		nil,
-	)
+// DeleteContext makes DELETE requests using the providers information and the given context
+func (c *Client) DeleteContext(ctx context.Context, authorizer Authorizer, uri string, options ...RequestOption) (*Response, error) {
+	req, err := c.newRequest(ctx, http.MethodDelete, uri, "", nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
+		return nil, err
 	}
 
-	return c.Do(authorizer, req, nil)
+	return c.Do(authorizer, req, nil, options...)
 }
 
 // Put makes PUT requests using the providers information
 // A request body can be passed through and attempt to be converted to JSON, this can also be left as nil
 // The response body will be bound to v
-func (c *Client) Put(authorizer Authorizer, uri string, body interface{}, v interface{}) (*http.Response, error) {
-	var req *http.Request
+func (c *Client) Put(authorizer Authorizer, uri string, body interface{}, v interface{}, options ...RequestOption) (*Response, error) {
+	return c.PutContext(context.Background(), authorizer, uri, body, v, options...)
+}
 
-	uri = strings.TrimLeft(uri, "/")
+// PutContext makes PUT requests using the providers information and the given context
+// A request body can be passed through and attempt to be converted to JSON, this can also be left as nil
+// The response body will be bound to v
+func (c *Client) PutContext(ctx context.Context, authorizer Authorizer, uri string, body interface{}, v interface{}, options ...RequestOption) (*Response, error) {
+	return c.doWithBody(ctx, http.MethodPut, authorizer, uri, body, v, options)
+}
 
-	b, err := internal.JsonifyBody(body)
-	if err != nil {
-		return nil, err
-	}
+// Patch makes PATCH requests using the providers information
+// A request body can be passed through and attempt to be converted to JSON, this can also be left as nil
+// The response body will be bound to v
+func (c *Client) Patch(authorizer Authorizer, uri string, body interface{}, v interface{}, options ...RequestOption) (*Response, error) {
+	return c.PatchContext(context.Background(), authorizer, uri, body, v, options...)
+}
 
-	req, err = http.NewRequest(
-		http.MethodPut,
-		fmt.Sprintf("%s://%s/%s", c.Protocol, c.URL, uri),
-		b,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
+// PatchContext makes PATCH requests using the providers information and the given context
+// A request body can be passed through and attempt to be converted to JSON, this can also be left as nil
+// The response body will be bound to v
+func (c *Client) PatchContext(ctx context.Context, authorizer Authorizer, uri string, body interface{}, v interface{}, options ...RequestOption) (*Response, error) {
+	return c.doWithBody(ctx, http.MethodPatch, authorizer, uri, body, v, options)
+}
 
-	return c.Do(authorizer, req, v)
+// Post makes POST requests using the providers information
+// A request body can be passed through and attempt to be converted to JSON, this can also be left as nil
+// The response body will be bound to v
+func (c *Client) Post(authorizer Authorizer, uri string, body interface{}, v interface{}, options ...RequestOption) (*Response, error) {
+	return c.PostContext(context.Background(), authorizer, uri, body, v, options...)
 }
 
-// Patch makes PATCH requests using the providers information
+// PostContext makes POST requests using the providers information and the given context
 // A request body can be passed through and attempt to be converted to JSON, this can also be left as nil
 // The response body will be bound to v
-func (c *Client) Patch(authorizer Authorizer, uri string, body interface{}, v interface{}) (*http.Response, error) {
-	var req *http.Request
+func (c *Client) PostContext(ctx context.Context, authorizer Authorizer, uri string, body interface{}, v interface{}, options ...RequestOption) (*Response, error) {
+	return c.doWithBody(ctx, http.MethodPost, authorizer, uri, body, v, options)
+}
 
+// doWithBody jsonifies body and performs a request with it using the given method
+func (c *Client) doWithBody(ctx context.Context, method string, authorizer Authorizer, uri string, body interface{}, v interface{}, options []RequestOption) (*Response, error) {
 	b, err := internal.JsonifyBody(body)
 	if err != nil {
 		return nil, err
 	}
 
-	uri = strings.TrimLeft(uri, "/")
-
-	req, err = http.NewRequest(
-		http.MethodPatch,
-		fmt.Sprintf("%s://%s/%s", c.Protocol, c.URL, uri),
-		b,
-	)
+	req, err := c.newRequest(ctx, method, uri, "", b)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
+		return nil, err
 	}
 
-	return c.Do(authorizer, req, v)
+	return c.Do(authorizer, req, v, options...)
 }
 
-// Post makes POST requests using the providers information
-// A request body can be passed through and attempt to be converted to JSON, this can also be left as nil
-// The response body will be bound to v
-func (c *Client) Post(authorizer Authorizer, uri string, body interface{}, v interface{}) (*http.Response, error) {
-	var req *http.Request
+// newRequest creates a new request against the clients URL and protocol
+// absolute http(s) uris, such as pagination links, are used as they are if they belong to the client, see checkHost
+// query is appended to the url as the querystring if it is not empty
+func (c *Client) newRequest(ctx context.Context, method string, uri string, query string, body io.Reader) (*http.Request, error) {
+	u := uri
+	if isAbsolute(uri) {
+		err := c.checkHost(uri)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		u = fmt.Sprintf("%s://%s/%s", c.Protocol, c.URL, strings.TrimLeft(uri, "/"))
+	}
 
-	b, err := internal.JsonifyBody(body)
+	if query != "" && strings.Contains(u, "?") {
+		u = fmt.Sprintf("%s&%s", u, query)
+	} else if query != "" {
+		u = fmt.Sprintf("%s?%s", u, query)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 
-	uri = strings.TrimLeft(uri, "/")
+	return req, nil
+}
 
-	req, err = http.NewRequest(
-		http.MethodPost,
-		fmt.Sprintf("%s://%s/%s", c.Protocol, c.URL, uri),
-		b,
-	)
+func isAbsolute(uri string) bool {
+	return strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://")
+}
+
+// checkHost returns an error unless the scheme and host of the absolute uri are those of the clients API,
+// upload or GraphQL urls, so the authorization header is never sent to other hosts
+func (c *Client) checkHost(uri string) error {
+	u, err := url.Parse(uri)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
+		return fmt.Errorf("failed to parse url: %v", err)
+	}
+
+	for _, allowed := range []string{fmt.Sprintf("%s://%s", c.Protocol, c.URL), c.uploadURL(), c.graphQLURL()} {
+		a, err := url.Parse(allowed)
+		if err == nil && strings.EqualFold(a.Scheme, u.Scheme) && strings.EqualFold(a.Host, u.Host) {
+			return nil
+		}
 	}
 
-	return c.Do(authorizer, req, v)
+	return fmt.Errorf("refusing to send request to %s://%s, it is not a host of the client", u.Scheme, u.Host)
 }
 
 // Do performs the given request using the providers details
-// This will also bind the JSON response to v
-func (c *Client) Do(authorizer Authorizer, req *http.Request, v interface{}) (*http.Response, error) {
-	if req.Header == nil {
-		req.Header = http.Header{}
+// The requests context is used for the request and when fetching the authorization header
+// This will also bind the JSON response to v, or the raw response if v is a *[]byte, *string or io.Writer
+// RequestOptions can be passed to customise the request
+// The response body is drained and closed once read, unless WithStream is used
+func (c *Client) Do(authorizer Authorizer, req *http.Request, v interface{}, options ...RequestOption) (*Response, error) {
+	o := newRequestOptions(options)
+	return c.roundTrip(authorizer, req, v, o, func(r *Request) (*http.Response, error) {
+		return c.do(r.Authorizer, r.Request, r.Target, !o.stream)
+	})
+}
+
+// roundTrip applies the options to req and passes it through the middleware chain to final,
+// recording its route, span, metrics and logs
+// The requests context is released once the body is closed if o.stream is set, otherwise before returning
+func (c *Client) roundTrip(authorizer Authorizer, req *http.Request, v interface{}, o *requestOptions, final func(r *Request) (*http.Response, error)) (*Response, error) {
+	route := RouteUnknown
+	switch {
+	case o.route != "":
+		route = o.route
+	case o.pathParams != nil:
+		route = req.URL.Path
 	}
+	req, cancel, err := o.apply(req)
+	if err != nil {
+		return nil, err
+	}
+	req = withRoute(req, route)
+
+	doer := c.chain(DoerFunc(func(r *Request) (*Response, error) {
+		res, err := final(r)
+		return newResponse(res), err
+	}))
+
+	r := &Request{Request: req, Authorizer: authorizer, Route: route, Target: v}
+	span := c.startRequestSpan(r)
+	start := time.Now()
+	res, err := doer.Do(r)
+	c.observeRequest(r, res, err, time.Since(start))
+	endRequestSpan(span, res, err)
+
+	var hres *http.Response
+	if res != nil {
+		hres = res.Response
+	}
+	if o.stream {
+		releaseOnClose(hres, cancel)
+	} else {
+		closeBody(hres)
+		cancel()
+	}
+
+	return res, err
+}
 
-	auth, err := authorizer.GetHeader()
+// do performs the request for Do once the request options have been applied
+// cacheable is false for streamed requests, which are never cached
+func (c *Client) do(authorizer Authorizer, req *http.Request, v interface{}, cacheable bool) (*http.Response, error) {
+	err := c.prepare(authorizer, req)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("Authorization", auth)
 
-	for _, h := range c.Headers {
-		req.Header.Add(h.Name, h.Value)
+	var cache Cache
+	var key string
+	var cached *CachedResponse
+	if cacheable && !isRaw(v) {
+		cache, key, cached = c.prepareConditional(authorizer, req)
 	}
 
-	res, err := c.client.Do(req)
+	res, err := c.send(c.httpClient(), authorizer, req)
 	if err != nil {
 		return res, err
 	}
 
+	if cache != nil {
+		err = applyConditional(cache, key, cached, res)
+		if err != nil {
+			return res, err
+		}
+		c.getMetrics().ObserveCache(requestRoute(req), res.Header.Get("X-From-Cache") == "1")
+	}
+
 	if v != nil && (res.StatusCode >= 200 && res.StatusCode < 300) {
-		decoder := json.NewDecoder(res.Body)
-		err = decoder.Decode(v)
+		err = decodeBody(res, v)
 		if err != nil {
 			return res, err
 		}
-	} else if res.StatusCode >= 400 && res.Body != nil {
-		// return the response body as error string if request failed/errored
-		buf := new(bytes.Buffer)
-		buf.ReadFrom(res.Body)
-		err = fmt.Errorf("%v", buf.String())
 	}
 
-	return res, err
+	return res, nil
+}
+
+// isRaw reports whether v receives the raw response body, see decodeBody
+func isRaw(v interface{}) bool {
+	switch v.(type) {
+	case *[]byte, *string, io.Writer:
+		return true
+	default:
+		return false
+	}
+}
+
+// decodeBody binds the response body to v
+// *[]byte, *string and io.Writer receive the raw body, used for non JSON media types
+// anything else is decoded from JSON
+func decodeBody(res *http.Response, v interface{}) error {
+	switch raw := v.(type) {
+	case *[]byte:
+		b, err := ioutil.ReadAll(res.Body)
+		*raw = b
+		return err
+	case *string:
+		b, err := ioutil.ReadAll(res.Body)
+		*raw = string(b)
+		return err
+	case io.Writer:
+		_, err := io.Copy(raw, res.Body)
+		return err
+	default:
+		decoder := json.NewDecoder(res.Body)
+		return decoder.Decode(v)
+	}
+}
+
+// prepare adds the authorization header and the clients headers to the request
+func (c *Client) prepare(authorizer Authorizer, req *http.Request) error {
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+
+	auth, err := getHeader(req.Context(), authorizer)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Authorization", auth)
+
+	c.mu.Lock()
+	headers := c.headers
+	c.mu.Unlock()
+
+	// headers already set on the request, such as Accept for a media type, take precedence
+	for _, h := range headers {
+		if len(req.Header.Values(h.Name)) == 0 {
+			req.Header.Add(h.Name, h.Value)
+		}
+	}
+
+	return nil
+}
+
+// send sends the request using hc, waiting for rate limits to reset if required
+// Requests are retried according to the clients retry policy and secondary rate limit settings
+// Responses with a status >= 400 are returned along with an error
+func (c *Client) send(hc *http.Client, authorizer Authorizer, req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	c.mu.Lock()
+	retries, maxWait, policy := c.secondaryRetries, c.secondaryMaxWait, c.retryPolicy
+	c.mu.Unlock()
+
+	attempts, secondary := 0, 0
+	for {
+		attempts++
+
+		err := c.waitForRateLimit(ctx, authorizer, req)
+		if err != nil {
+			return nil, withAttempts(attempts, err)
+		}
+
+		res, err := hc.Do(req)
+		if err == nil {
+			c.updateRateLimit(authorizer, req, res)
+			err = checkResponse(res)
+		}
+
+		if err == nil {
+			return res, nil
+		}
+
+		var delay time.Duration
+		var serr *SecondaryRateLimitError
+		switch {
+		case errors.As(err, &serr):
+			if secondary >= retries || serr.RetryAfter > maxWait {
+				return res, withAttempts(attempts, err)
+			}
+			secondary++
+			delay = serr.RetryAfter
+		case policy.retryable(req, res, err) && attempts < policy.MaxAttempts:
+			delay = policy.backoff(attempts)
+		default:
+			return res, withAttempts(attempts, err)
+		}
+
+		if !rewindBody(req) {
+			return res, withAttempts(attempts, err)
+		}
+
+		closeBody(res)
+
+		c.getMetrics().IncRetry(req.Method, requestRoute(req))
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+			attribute.Int("github.attempt", attempts),
+			attribute.String("github.retry_delay", delay.String()),
+		))
+		c.logRetry(ctx, requestRoute(req), attempts, delay, err)
+		err = sleep(ctx, delay)
+		if err != nil {
+			return nil, withAttempts(attempts, err)
+		}
+	}
+}
+
+// checkResponse returns an error for responses with a status >= 400
+// The error will be a *SecondaryRateLimitError or *ErrorResponse
+func checkResponse(res *http.Response) error {
+	if res.StatusCode < 400 {
+		return nil
+	}
+
+	e := newErrorResponse(res)
+	if serr := checkSecondaryRateLimit(e); serr != nil {
+		return serr
+	}
+
+	return e
+}
+
+// rewindBody resets the requests body so it can be sent again
+// returns false if the body cannot be rewound
+func rewindBody(req *http.Request) bool {
+	if req.Body == nil || req.Body == http.NoBody {
+		return true
+	}
+
+	if req.GetBody == nil {
+		return false
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return false
+	}
+
+	req.Body = body
+	return true
 }
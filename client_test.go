@@ -1,10 +1,19 @@
 package crusch
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/weavc/crusch/internal"
 )
@@ -183,6 +192,245 @@ func TestPost(t *testing.T) {
 	}
 }
 
+func TestContextMethods(t *testing.T) {
+	client := setupClient(generalResponse)
+	ctx := context.Background()
+
+	var v m
+	_, err := client.GetContext(ctx, setupAuth(), "test/uri", nil, &v)
+	if err != nil {
+		t.Errorf("valid get context: unexpected %v", err)
+	}
+
+	_, err = client.PostContext(ctx, setupAuth(), "test/uri", &m{Weavc: "crusch", One: "1"}, &v)
+	if err != nil {
+		t.Errorf("valid post context: unexpected %v", err)
+	}
+
+	_, err = client.PutContext(ctx, setupAuth(), "test/uri", nil, &v)
+	if err != nil {
+		t.Errorf("valid put context: unexpected %v", err)
+	}
+
+	_, err = client.PatchContext(ctx, setupAuth(), "test/uri", nil, &v)
+	if err != nil {
+		t.Errorf("valid patch context: unexpected %v", err)
+	}
+
+	_, err = client.DeleteContext(ctx, setupAuth(), "test/uri")
+	if err != nil {
+		t.Errorf("valid delete context: unexpected %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err = client.GetContext(cancelled, setupAuth(), "test/uri", nil, &v)
+	if err != context.Canceled {
+		t.Errorf("cancelled get context: returned %v want %v", err, context.Canceled)
+	}
+
+	var got context.Context
+	auth := ContextAuthorizerFunc(func(ctx context.Context) (string, error) {
+		got = ctx
+		return "bearer randombearertokenexample", nil
+	})
+
+	type key struct{}
+	valued := context.WithValue(ctx, key{}, "crusch")
+	_, err = client.GetContext(valued, auth, "test/uri", nil, &v)
+	if err != nil {
+		t.Errorf("context authorizer: unexpected %v", err)
+	}
+	if got == nil || got.Value(key{}) != "crusch" {
+		t.Errorf("context authorizer: context was not passed to authorizer")
+	}
+}
+
+func TestAbsoluteURLs(t *testing.T) {
+	var foreign int32
+	stray := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&foreign, 1)
+	}))
+	defer stray.Close()
+
+	client := setupClient(generalResponse)
+	client.URL = "github.example.com/api/v3"
+
+	cases := []struct {
+		name string
+		uri  string
+		ok   bool
+	}{
+		{"api host", "http://github.example.com/api/v3/repos/weavc/crusch", true},
+		{"graphql url", "http://github.example.com/api/graphql", true},
+		{"upload url", "http://github.example.com/api/uploads/repos/weavc/crusch/releases/1/assets", true},
+		{"other scheme", "https://github.example.com/api/v3/repos/weavc/crusch", false},
+		{"other host", "http://github.example.org/api/v3/repos/weavc/crusch", false},
+		{"other port", "http://github.example.com:8080/api/v3/repos/weavc/crusch", false},
+		{"stray host", stray.URL + "/x", false},
+	}
+
+	for _, c := range cases {
+		_, err := client.Get(setupAuth(), c.uri, nil, nil)
+		if c.ok && err != nil {
+			t.Errorf("%s: unexpected %v", c.name, err)
+		}
+		if !c.ok && err == nil {
+			t.Errorf("%s: returned nil want error", c.name)
+		}
+	}
+
+	client = NewGithubClient("api.github.com", "https")
+	client.SetHTTPClient(stray.Client())
+	_, err := client.Get(setupAuth(), stray.URL+"/x", nil, nil)
+	if err == nil || atomic.LoadInt32(&foreign) != 0 {
+		t.Errorf("stray host: returned %v, %d requests sent want error and none sent", err, foreign)
+	}
+
+	_, err = client.Post(setupAuth(), "https://uploads.github.com.example.org/x", nil, nil)
+	if err == nil {
+		t.Errorf("lookalike upload host: returned nil want error")
+	}
+}
+
+func TestResponseBodyClosed(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		body   string
+		v      interface{}
+	}{
+		{name: "decoded", status: 200, body: `{"weavc": "crusch"}`, v: &m{}},
+		{name: "not decoded", status: 200, body: `{"weavc": "crusch"}`},
+		{name: "raw", status: 200, body: "crusch", v: new(string)},
+		{name: "decode error", status: 200, body: "not json", v: &m{}},
+		{name: "error", status: 404, body: `{"message": "Not Found"}`, v: &m{}},
+		{name: "retried", status: 503, body: "unavailable"},
+	}
+
+	for _, c := range cases {
+		rt := &fakeTransport{status: c.status, body: c.body}
+		client := setupFakeClient(rt)
+		client.SetRetryPolicy(&RetryPolicy{MaxAttempts: 3, Statuses: []int{503}, Methods: []string{http.MethodGet}})
+
+		res, _ := client.Get(setupAuth(), "test/uri", nil, c.v)
+		if open := rt.Open(); open != 0 {
+			t.Errorf("%s: left %d bodies open", c.name, open)
+		}
+
+		b, err := ioutil.ReadAll(res.Body)
+		if err != nil || len(b) != 0 {
+			t.Errorf("%s: read closed body %q, %v", c.name, b, err)
+		}
+	}
+
+	rt := &fakeTransport{body: "crusch"}
+	client := setupFakeClient(rt)
+
+	res, err := client.Get(setupAuth(), "test/uri", nil, nil, WithStream())
+	if err != nil || rt.Open() != 1 {
+		t.Fatalf("stream: returned %d open bodies, %v want 1", rt.Open(), err)
+	}
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil || string(b) != "crusch" {
+		t.Errorf("stream: read %q, %v want %q", b, err, "crusch")
+	}
+
+	res.Body.Close()
+	if open := rt.Open(); open != 0 {
+		t.Errorf("stream: left %d bodies open after close", open)
+	}
+}
+
+func TestClientConcurrentUse(t *testing.T) {
+	client := setupClient(generalResponse)
+	client.SetCache(NewMemoryCache(10))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			var v m
+			_, err := client.Get(setupAuth(), "test/uri", nil, &v)
+			if err != nil {
+				t.Errorf("concurrent get: unexpected %v", err)
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("X-Test-%d", i%3)
+			client.AddHeader(name, "crusch")
+			client.RemoveHeader(name)
+			client.SetRateLimitWait(i%2 == 0)
+			client.SetRetryPolicy(DefaultRetryPolicy())
+		}(i)
+		go func() {
+			defer wg.Done()
+			clone := client.WithDefaultHeader("X-Clone", "crusch")
+			_, err := clone.Post(setupAuth(), "test/uri", generalResponse, nil)
+			if err != nil {
+				t.Errorf("concurrent clone: unexpected %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRemoveHeader(t *testing.T) {
+	client := NewGithubClient("doesnt.matter", "http")
+	client.AddHeader("X-A", "a")
+	client.AddHeader("X-B", "b")
+	client.AddHeader("X-A", "a2")
+
+	// slices held by in flight requests must not be modified
+	headers := client.headers
+	client.RemoveHeader("X-B")
+
+	want := http.Header{"Accept": {"application/vnd.github.machine-man-preview+json"}, "X-A": {"a2"}}
+	if got := client.Headers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("remove header: returned %v want %v", got, want)
+	}
+
+	if len(headers) != 3 || headers[1].Name != "X-B" {
+		t.Errorf("remove header: previous headers were modified %v", headers)
+	}
+}
+
+func TestClone(t *testing.T) {
+	rt := &fakeTransport{}
+	client := setupFakeClient(rt)
+	client.SetSecondaryRateLimitRetry(2, time.Second)
+
+	clone := client.WithDefaultHeader("X-Clone", "crusch")
+	clone.RemoveHeader("Accept")
+
+	if h := client.Headers(); len(h) != 1 || h.Get("Accept") == "" {
+		t.Errorf("clone: original headers were modified %v", h)
+	}
+
+	_, err := clone.Get(setupAuth(), "test/uri", nil, nil)
+	if err != nil || rt.Request().Header.Get("X-Clone") != "crusch" || rt.Request().Header.Get("Accept") != "" {
+		t.Errorf("clone: sent headers %v, %v", rt.Request().Header, err)
+	}
+
+	if clone.secondaryRetries != 2 || clone.secondaryMaxWait != time.Second {
+		t.Errorf("clone: configuration was not copied")
+	}
+
+	other := &fakeTransport{}
+	moved := client.WithURL("other.url", "https").WithHTTPClient(&http.Client{Transport: other})
+	_, err = moved.Get(setupAuth(), "test/uri", nil, nil)
+	if err != nil || other.Request().URL.String() != "https://other.url/test/uri" {
+		t.Errorf("with url: requested %v, %v", other.Request().URL, err)
+	}
+	if client.URL != "doesnt.matter" {
+		t.Errorf("with url: original url was modified %s", client.URL)
+	}
+}
+
 func TestParseQuery(t *testing.T) {
 	var s string = "one=1&weavc=crusch"
 
@@ -236,10 +484,15 @@ func setupClient(body interface{}) *Client {
 	return client
 }
 
+// setupFakeClient creates a client sending its requests through rt
+func setupFakeClient(rt *fakeTransport) *Client {
+	client := NewGithubClient("doesnt.matter", "http")
+	client.SetHTTPClient(&http.Client{Transport: rt})
+	return client
+}
+
 func setupAuth() Authorizer {
-	return AuthorizerFunc(func() (string, error) {
-		return "bearer randombearertokenexample", nil
-	})
+	return &OAuth{Token: "randombearertokenexample"}
 }
 
 type testTransport struct {
@@ -258,3 +511,138 @@ func (t *testTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 		Request:    req,
 	}, nil
 }
+
+// fakeTransport is a configurable http.RoundTripper, recording the requests it receives
+// Responses are written by handler if it is set, otherwise from status (200 if 0), header and body,
+// where string bodies are sent as they are and anything else as JSON
+// The first errors requests fail with err, and responses wait for delay unless the request is cancelled
+type fakeTransport struct {
+	status  int
+	header  http.Header
+	body    interface{}
+	handler http.HandlerFunc
+	err     error
+	errors  int
+	delay   time.Duration
+
+	mu    sync.Mutex
+	calls int
+	req   *http.Request
+	open  int32
+}
+
+func (t *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.calls++
+	calls := t.calls
+	t.req = req
+	t.mu.Unlock()
+
+	if calls <= t.errors {
+		return nil, t.err
+	}
+
+	if t.delay > 0 {
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(t.delay):
+		}
+	}
+
+	w := httptest.NewRecorder()
+	if t.handler != nil {
+		t.handler(w, req)
+	} else {
+		err := t.write(w)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res := w.Result()
+	res.Request = req
+	atomic.AddInt32(&t.open, 1)
+	res.Body = &fakeBody{ReadCloser: res.Body, open: &t.open}
+	return res, nil
+}
+
+// write writes the configured status, header and body to w
+func (t *fakeTransport) write(w http.ResponseWriter) error {
+	var body io.Reader
+	if s, ok := t.body.(string); ok {
+		body = strings.NewReader(s)
+	} else {
+		b, err := internal.JsonifyBody(t.body)
+		if err != nil {
+			return err
+		}
+		body = b
+	}
+
+	for k, v := range t.header {
+		w.Header()[k] = append([]string{}, v...)
+	}
+
+	status := t.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+
+	_, err := io.Copy(w, body)
+	return err
+}
+
+// Calls returns the number of requests received since the transport was created or reset
+func (t *fakeTransport) Calls() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.calls
+}
+
+// Reset resets the number of requests received
+func (t *fakeTransport) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.calls = 0
+}
+
+// Request returns the last request received
+func (t *fakeTransport) Request() *http.Request {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.req
+}
+
+// Open returns the number of response bodies which have not been closed
+func (t *fakeTransport) Open() int32 {
+	return atomic.LoadInt32(&t.open)
+}
+
+type fakeBody struct {
+	io.ReadCloser
+	open   *int32
+	closed int32
+}
+
+func (b *fakeBody) Close() error {
+	if atomic.CompareAndSwapInt32(&b.closed, 0, 1) {
+		atomic.AddInt32(b.open, -1)
+	}
+	return b.ReadCloser.Close()
+}
+
+// respondJSON responds with status and v as the body
+func respondJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// echo responds with the body of the request
+func echo(w http.ResponseWriter, req *http.Request) {
+	if req.Body != nil {
+		io.Copy(w, req.Body)
+	}
+}
@@ -0,0 +1,175 @@
+package crusch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// DownloadOptions configures a download
+type DownloadOptions struct {
+	// Offset resumes a download from the given byte, using a Range request
+	Offset int64
+	// Accept overrides the Accept header of the request
+	// i.e. application/octet-stream is required to download release assets
+	Accept string
+}
+
+// Download requests uri, returning the response body without reading it
+// This can be used for tarballs, zipballs, Actions artifacts, logs and other non JSON content
+// Redirects to storage hosts are followed without forwarding the Authorization header
+// Redirects to the clients own hosts, such as for renamed repositories, are authorized as the original request was
+// The caller is responsible for closing the returned body
+func (c *Client) Download(authorizer Authorizer, uri string, opts *DownloadOptions, options ...RequestOption) (io.ReadCloser, *Response, error) {
+	return c.DownloadContext(context.Background(), authorizer, uri, opts, options...)
+}
+
+// DownloadContext is Download using the given context
+func (c *Client) DownloadContext(ctx context.Context, authorizer Authorizer, uri string, opts *DownloadOptions, options ...RequestOption) (io.ReadCloser, *Response, error) {
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+
+	req, err := c.newRequest(ctx, http.MethodGet, uri, "", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	o := newRequestOptions(options)
+	o.stream = true
+	res, err := c.roundTrip(authorizer, req, nil, o, func(r *Request) (*http.Response, error) {
+		body, res, err := c.download(r.Authorizer, r.Request, opts)
+		if err == nil {
+			res.Body = body
+		}
+		return res, err
+	})
+	if err == nil && (res == nil || res.Response == nil || res.Body == nil) {
+		err = fmt.Errorf("no response body to download")
+	}
+	if err != nil {
+		// closing the body releases the requests context
+		if res != nil && res.Response != nil && res.Body != nil {
+			res.Body.Close()
+		}
+		return nil, res, err
+	}
+
+	return res.Body, res, nil
+}
+
+// download performs the request for DownloadContext once the request options have been applied
+func (c *Client) download(authorizer Authorizer, req *http.Request, opts *DownloadOptions) (io.ReadCloser, *http.Response, error) {
+	err := c.prepare(authorizer, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	setDownloadHeaders(req, opts)
+
+	// stop at redirects so the Authorization header is never sent to the storage host
+	hc := *c.httpClient()
+	hc.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	res, err := c.send(&hc, authorizer, req)
+	if err != nil {
+		return nil, res, err
+	}
+
+	if isRedirect(res.StatusCode) {
+		res, err = c.followDownload(&hc, req, res, opts)
+		if err != nil {
+			return nil, res, err
+		}
+	}
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusPartialContent {
+		res.Body.Close()
+		return nil, res, fmt.Errorf("unexpected status downloading %s: %s", req.URL.Redacted(), res.Status)
+	}
+
+	// skip to the offset ourselves if the range was ignored
+	if opts.Offset > 0 && res.StatusCode == http.StatusOK {
+		_, err = io.CopyN(ioutil.Discard, res.Body, opts.Offset)
+		if err != nil {
+			res.Body.Close()
+			return nil, res, fmt.Errorf("failed to skip to offset %d: %v", opts.Offset, err)
+		}
+	}
+
+	return res.Body, res, nil
+}
+
+// DownloadTo requests uri, writing the response body to w
+// returns the number of bytes written
+func (c *Client) DownloadTo(authorizer Authorizer, uri string, w io.Writer, opts *DownloadOptions, options ...RequestOption) (int64, *Response, error) {
+	return c.DownloadToContext(context.Background(), authorizer, uri, w, opts, options...)
+}
+
+// DownloadToContext is DownloadTo using the given context
+func (c *Client) DownloadToContext(ctx context.Context, authorizer Authorizer, uri string, w io.Writer, opts *DownloadOptions, options ...RequestOption) (int64, *Response, error) {
+	body, res, err := c.DownloadContext(ctx, authorizer, uri, opts, options...)
+	if err != nil {
+		return 0, res, err
+	}
+	defer body.Close()
+
+	n, err := io.Copy(w, body)
+	return n, res, err
+}
+
+// maxDownloadRedirects is the number of redirects followed by a download, matching http.Client
+const maxDownloadRedirects = 10
+
+// followDownload follows the redirects from res using hc, which must not follow redirects itself
+// Redirects to the clients own hosts keep the headers of req, including Authorization, others are sent without them
+func (c *Client) followDownload(hc *http.Client, req *http.Request, res *http.Response, opts *DownloadOptions) (*http.Response, error) {
+	for redirects := 0; isRedirect(res.StatusCode); redirects++ {
+		res.Body.Close()
+		if redirects == maxDownloadRedirects {
+			return res, fmt.Errorf("stopped downloading after %d redirects", maxDownloadRedirects)
+		}
+
+		location, err := res.Location()
+		if err != nil {
+			return res, fmt.Errorf("invalid redirect for download: %v", err)
+		}
+
+		redirect, err := http.NewRequestWithContext(req.Context(), http.MethodGet, location.String(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		if c.checkHost(location.String()) == nil {
+			redirect.Header = req.Header.Clone()
+		}
+		setDownloadHeaders(redirect, opts)
+
+		res, err = hc.Do(redirect)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	return res, checkResponse(res)
+}
+
+func setDownloadHeaders(req *http.Request, opts *DownloadOptions) {
+	if opts.Accept != "" {
+		req.Header.Set("Accept", opts.Accept)
+	}
+	if opts.Offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", opts.Offset))
+	}
+}
+
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package crusch
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownload(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+
+	var storageAuth string
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		storageAuth = r.Header.Get("Authorization")
+		http.ServeContent(w, r, "crusch.tar.gz", time.Time{}, strings.NewReader(content))
+	}))
+	defer storage.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/repos/weavc/renamed/tarball/master" {
+			http.Redirect(w, r, "/repos/weavc/crusch/tarball/master", http.StatusMovedPermanently)
+			return
+		}
+		if r.URL.Path == "/repos/weavc/crusch/tarball/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Not Found"}`))
+			return
+		}
+		http.Redirect(w, r, storage.URL+"/weavc/crusch/legacy.tar.gz", http.StatusFound)
+	}))
+	defer api.Close()
+
+	client := NewGithubClient(strings.TrimPrefix(api.URL, "http://"), "http")
+	metrics := NewMemoryMetrics()
+	client.SetMetrics(metrics)
+
+	body, res, err := client.Download(setupAuth(), "repos/weavc/crusch/tarball/master", nil)
+	if err != nil {
+		t.Fatalf("download: unexpected %v", err)
+	}
+
+	b, _ := ioutil.ReadAll(body)
+	body.Close()
+	if string(b) != content || res.StatusCode != 200 {
+		t.Errorf("download: returned %d bytes, status %d", len(b), res.StatusCode)
+	}
+
+	if storageAuth != "" {
+		t.Errorf("download: authorization header was forwarded to the storage host")
+	}
+
+	// renamed repositories redirect within the api, which must still be authorized
+	var renamed bytes.Buffer
+	_, _, err = client.DownloadTo(setupAuth(), "repos/weavc/renamed/tarball/master", &renamed, nil)
+	if err != nil || renamed.String() != content || storageAuth != "" {
+		t.Errorf("download renamed: returned %d bytes, %v, storage authorization %q", renamed.Len(), err, storageAuth)
+	}
+
+	var buf bytes.Buffer
+	n, res, err := client.DownloadTo(setupAuth(), "repos/weavc/crusch/tarball/master", &buf, &DownloadOptions{Offset: 990})
+	if err != nil {
+		t.Fatalf("download resume: unexpected %v", err)
+	}
+
+	if n != 10 || buf.String() != "0123456789" || res.StatusCode != http.StatusPartialContent {
+		t.Errorf("download resume: returned %q, status %d", buf.String(), res.StatusCode)
+	}
+
+	_, _, err = client.Download(setupAuth(), "repos/weavc/crusch/tarball/missing", nil)
+	if !IsNotFound(err) {
+		t.Errorf("download missing: returned %v", err)
+	}
+
+	_, _, err = client.DownloadTo(setupAuth(), "repos/{owner}/{repo}/tarball/{ref}", ioutil.Discard, nil,
+		WithPathParams(map[string]string{"owner": "weavc", "repo": "crusch", "ref": "master"}))
+	if err != nil {
+		t.Fatalf("download template: unexpected %v", err)
+	}
+
+	want := []string{"GET /repos/{owner}/{repo}/tarball/{ref} 200", "GET unknown 200", "GET unknown 206", "GET unknown 404"}
+	if keys := metrics.RequestKeys(); !reflect.DeepEqual(keys, want) {
+		t.Errorf("download metrics: recorded %v want %v", keys, want)
+	}
+}
+
+func TestDownloadIgnoredRange(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+	defer api.Close()
+
+	client := NewGithubClient(strings.TrimPrefix(api.URL, "http://"), "http")
+
+	var buf bytes.Buffer
+	_, _, err := client.DownloadTo(setupAuth(), "repos/weavc/crusch/actions/jobs/1/logs", &buf, &DownloadOptions{Offset: 4})
+	if err != nil || buf.String() != "456789" {
+		t.Errorf("download ignored range: returned %q, %v", buf.String(), err)
+	}
+}
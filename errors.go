@@ -0,0 +1,143 @@
+package crusch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// ErrorResponse is returned when Github responds to a request with a status >= 400
+// https://docs.github.com/en/rest/overview/resources-in-the-rest-api#client-errors
+type ErrorResponse struct {
+	// Response is the response that caused the error, its body has already been read
+	Response *http.Response `json:"-"`
+	// Request is the request that was sent
+	Request    *http.Request `json:"-"`
+	StatusCode int           `json:"-"`
+	// RequestID is the X-GitHub-Request-Id of the response, useful when contacting Github support
+	RequestID        string  `json:"-"`
+	Message          string  `json:"message"`
+	DocumentationURL string  `json:"documentation_url"`
+	Errors           []Error `json:"errors"`
+}
+
+// Error is a single error detailed in an ErrorResponse, usually from a 422 validation failure
+// https://docs.github.com/en/rest/overview/resources-in-the-rest-api#client-errors
+type Error struct {
+	Resource string `json:"resource"`
+	Field    string `json:"field"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+}
+
+// UnmarshalJSON decodes an Error, some endpoints return plain strings instead of objects
+func (e *Error) UnmarshalJSON(b []byte) error {
+	var message string
+	if json.Unmarshal(b, &message) == nil {
+		*e = Error{Message: message}
+		return nil
+	}
+
+	type plain Error
+	return json.Unmarshal(b, (*plain)(e))
+}
+
+func (e *Error) Error() string {
+	if e.Message != "" && e.Code == "" {
+		return e.Message
+	}
+	if e.Message != "" {
+		return fmt.Sprintf("%s: %s %s (%s)", e.Code, e.Resource, e.Field, e.Message)
+	}
+	return fmt.Sprintf("%s: %s %s", e.Code, e.Resource, e.Field)
+}
+
+func (e *ErrorResponse) Error() string {
+	var b strings.Builder
+
+	if e.Request != nil {
+		fmt.Fprintf(&b, "%s %s: ", e.Request.Method, e.Request.URL.Redacted())
+	}
+	fmt.Fprintf(&b, "%d %s", e.StatusCode, e.Message)
+
+	for i := range e.Errors {
+		fmt.Fprintf(&b, ", %s", e.Errors[i].Error())
+	}
+
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " [request id %s]", e.RequestID)
+	}
+
+	return b.String()
+}
+
+// newErrorResponse reads and closes the responses body, parsing it into an ErrorResponse
+// Bodies which are not JSON are used as the message
+func newErrorResponse(res *http.Response) *ErrorResponse {
+	e := &ErrorResponse{
+		Response:   res,
+		Request:    res.Request,
+		StatusCode: res.StatusCode,
+		RequestID:  res.Header.Get("X-GitHub-Request-Id"),
+	}
+
+	if res.Body == nil {
+		return e
+	}
+
+	b, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		e.Message = fmt.Sprintf("failed to read response body: %v", err)
+		return e
+	}
+
+	if json.Unmarshal(b, e) != nil {
+		e.Message = strings.TrimSpace(string(b))
+	}
+
+	return e
+}
+
+// IsNotFound reports whether err is an ErrorResponse with a 404 status
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsForbidden reports whether err is an ErrorResponse with a 403 status
+func IsForbidden(err error) bool {
+	return hasStatus(err, http.StatusForbidden)
+}
+
+// IsValidationFailed reports whether err is an ErrorResponse with a 422 status
+// the failed validations are detailed in ErrorResponse.Errors
+func IsValidationFailed(err error) bool {
+	return hasStatus(err, http.StatusUnprocessableEntity)
+}
+
+// IsRateLimited reports whether err was caused by exceeding either the primary or secondary rate limits
+func IsRateLimited(err error) bool {
+	var serr *SecondaryRateLimitError
+	if errors.As(err, &serr) {
+		return true
+	}
+
+	var e *ErrorResponse
+	if !errors.As(err, &e) || e.Response == nil {
+		return false
+	}
+
+	if e.StatusCode != http.StatusForbidden && e.StatusCode != http.StatusTooManyRequests {
+		return false
+	}
+
+	return e.Response.Header.Get("X-RateLimit-Remaining") == "0"
+}
+
+func hasStatus(err error, status int) bool {
+	var e *ErrorResponse
+	return errors.As(err, &e) && e.StatusCode == status
+}
@@ -0,0 +1,103 @@
+package crusch
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestErrorResponse(t *testing.T) {
+	client := setupFakeClient(&fakeTransport{
+		status: http.StatusNotFound,
+		header: http.Header{"X-Github-Request-Id": {"ABCD:1234"}},
+		body:   `{"message": "Not Found", "documentation_url": "https://docs.github.com/rest"}`,
+	})
+
+	_, err := client.Get(setupAuth(), "repos/weavc/missing", nil, nil)
+
+	var e *ErrorResponse
+	if !errors.As(err, &e) {
+		t.Fatalf("not found: returned %v want *ErrorResponse", err)
+	}
+
+	if e.StatusCode != 404 || e.Message != "Not Found" || e.DocumentationURL != "https://docs.github.com/rest" {
+		t.Errorf("not found: returned %+v", e)
+	}
+
+	if e.RequestID != "ABCD:1234" {
+		t.Errorf("not found: request id %s want %s", e.RequestID, "ABCD:1234")
+	}
+
+	if e.Request == nil || e.Request.URL.Path != "/repos/weavc/missing" {
+		t.Errorf("not found: missing originating request")
+	}
+
+	if !IsNotFound(err) || IsForbidden(err) || IsValidationFailed(err) || IsRateLimited(err) {
+		t.Errorf("not found: helpers returned incorrect results for %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "404 Not Found") || !strings.Contains(err.Error(), "ABCD:1234") {
+		t.Errorf("not found: error message %q", err.Error())
+	}
+}
+
+func TestErrorResponseValidation(t *testing.T) {
+	body := `{"message": "Validation Failed", "errors": [` +
+		`{"resource": "Issue", "field": "title", "code": "missing_field"}, "title is too long"]}`
+	client := setupFakeClient(&fakeTransport{status: http.StatusUnprocessableEntity, body: body})
+
+	_, err := client.Post(setupAuth(), "repos/weavc/crusch/issues", nil, nil)
+	if !IsValidationFailed(err) {
+		t.Fatalf("validation failed: returned %v", err)
+	}
+
+	var e *ErrorResponse
+	errors.As(err, &e)
+
+	want := []Error{
+		{Resource: "Issue", Field: "title", Code: "missing_field"},
+		{Message: "title is too long"},
+	}
+	if !reflect.DeepEqual(e.Errors, want) {
+		t.Errorf("validation failed: errors %+v want %+v", e.Errors, want)
+	}
+}
+
+func TestErrorResponseNonJSON(t *testing.T) {
+	client := setupFakeClient(&fakeTransport{status: http.StatusBadGateway, body: "<html>bad gateway</html>\n"})
+
+	_, err := client.Get(setupAuth(), "test/uri", nil, nil)
+
+	var e *ErrorResponse
+	if !errors.As(err, &e) || e.Message != "<html>bad gateway</html>" {
+		t.Errorf("non json: returned %v", err)
+	}
+}
+
+func TestIsRateLimited(t *testing.T) {
+	h := http.Header{}
+	h.Set("X-RateLimit-Remaining", "0")
+	client := setupFakeClient(&fakeTransport{status: http.StatusForbidden, header: h, body: `{"message": "API rate limit exceeded"}`})
+
+	_, err := client.Get(setupAuth(), "test/uri", nil, nil)
+	if !IsRateLimited(err) || !IsForbidden(err) {
+		t.Errorf("primary rate limit: returned %v", err)
+	}
+
+	h.Set("Retry-After", "30")
+	_, err = client.Get(setupAuth(), "test/uri", nil, nil)
+	if !IsRateLimited(err) {
+		t.Errorf("secondary rate limit: returned %v", err)
+	}
+
+	var e *ErrorResponse
+	if !errors.As(err, &e) {
+		t.Errorf("secondary rate limit: does not unwrap to *ErrorResponse")
+	}
+
+	if IsRateLimited(errors.New("unrelated")) || IsNotFound(nil) {
+		t.Errorf("unrelated error: reported as github error")
+	}
+}
@@ -0,0 +1,570 @@
+package githubtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// caller is the app or installation making a request
+type caller struct {
+	app          *App
+	installation *Installation
+}
+
+// identity keys the rate limit of the caller
+func (c *caller) identity() string {
+	if c.installation != nil {
+		return fmt.Sprintf("installation/%d", c.installation.ID)
+	}
+	return fmt.Sprintf("app/%d", c.app.ID)
+}
+
+type handler func(w http.ResponseWriter, r *http.Request, c *caller)
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /rate_limit", s.installation(s.getRateLimit))
+
+	mux.HandleFunc("GET /app", s.app(s.getApp))
+	mux.HandleFunc("GET /app/installations", s.app(s.listInstallations))
+	mux.HandleFunc("POST /app/installations/{id}/access_tokens", s.app(s.createToken))
+
+	mux.HandleFunc("GET /installation/repositories", s.installation(s.listRepositories))
+	mux.HandleFunc("GET /repos/{owner}/{repo}", s.repo("metadata", "read", s.getRepository))
+
+	mux.HandleFunc("GET /repos/{owner}/{repo}/issues", s.repo("issues", "read", s.listIssues))
+	mux.HandleFunc("POST /repos/{owner}/{repo}/issues", s.repo("issues", "write", s.createIssue))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/issues/{number}", s.repo("issues", "read", s.getIssue))
+	mux.HandleFunc("PATCH /repos/{owner}/{repo}/issues/{number}", s.repo("issues", "write", s.updateIssue))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/issues/{number}/comments", s.repo("issues", "read", s.listComments))
+	mux.HandleFunc("POST /repos/{owner}/{repo}/issues/{number}/comments", s.repo("issues", "write", s.createComment))
+
+	mux.HandleFunc("POST /repos/{owner}/{repo}/check-runs", s.repo("checks", "write", s.createCheckRun))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/check-runs/{id}", s.repo("checks", "read", s.getCheckRun))
+	mux.HandleFunc("PATCH /repos/{owner}/{repo}/check-runs/{id}", s.repo("checks", "write", s.updateCheckRun))
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "Not Found")
+	})
+
+	return mux
+}
+
+// app authenticates requests using an app JWT
+func (s *Server) app(next handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		bearer, ok := authorization(r, "bearer")
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "A JSON web token could not be decoded")
+			return
+		}
+
+		app, err := s.verifyJWT(bearer)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		c := &caller{app: app}
+		if s.limit(w, c) {
+			next(w, r, c)
+		}
+	}
+}
+
+// installation authenticates requests using an installation access token
+func (s *Server) installation(next handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		t, ok := authorization(r, "token")
+		if !ok {
+			t, ok = authorization(r, "bearer")
+		}
+
+		tok, found := s.tokens[t]
+		if !ok || !found || time.Now().After(tok.expires) {
+			writeError(w, http.StatusUnauthorized, "Bad credentials")
+			return
+		}
+
+		c := &caller{installation: tok.installation}
+		if s.limit(w, c) {
+			next(w, r, c)
+		}
+	}
+}
+
+// repo authenticates requests to a repository, requiring the installation has the permission at level
+func (s *Server) repo(permission string, level string, next func(w http.ResponseWriter, r *http.Request, repo *Repository)) http.HandlerFunc {
+	return s.installation(func(w http.ResponseWriter, r *http.Request, c *caller) {
+		name := fmt.Sprintf("%s/%s", r.PathValue("owner"), r.PathValue("repo"))
+		repo, ok := s.repositories[name]
+		if !ok || !contains(c.installation.repositories, name) {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+
+		granted := c.installation.Permissions[permission]
+		if granted != "write" && (granted != "read" || level != "read") {
+			writeError(w, http.StatusForbidden, "Resource not accessible by integration")
+			return
+		}
+
+		next(w, r, repo)
+	})
+}
+
+// verifyJWT verifies the JWT was signed by the app it was issued by, mu must be held
+func (s *Server) verifyJWT(bearer string) (*App, error) {
+	var app *App
+	_, err := jwt.ParseWithClaims(bearer, &jwt.StandardClaims{}, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+
+		claims := t.Claims.(*jwt.StandardClaims)
+		id, err := strconv.ParseInt(claims.Issuer, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("'Issuer' claim ('iss') must be an Integer")
+		}
+
+		var ok bool
+		app, ok = s.apps[id]
+		if !ok {
+			return nil, fmt.Errorf("Integration not found")
+		}
+
+		if claims.ExpiresAt == 0 || time.Unix(claims.ExpiresAt, 0).After(time.Now().Add(10*time.Minute)) {
+			return nil, fmt.Errorf("'Expiration time' claim ('exp') is too far in the future")
+		}
+
+		return app.key, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("A JSON web token could not be decoded: %v", err)
+	}
+
+	return app, nil
+}
+
+// limit applies the rate limit of the caller, writing the rate limit headers
+// returns false if the limit has been exhausted, mu must be held
+func (s *Server) limit(w http.ResponseWriter, c *caller) bool {
+	used := s.used[c.identity()]
+	exceeded := used >= s.rateLimit
+	if !exceeded {
+		used++
+		s.used[c.identity()] = used
+	}
+
+	h := w.Header()
+	h.Set("X-RateLimit-Limit", strconv.Itoa(s.rateLimit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(s.rateLimit-used))
+	h.Set("X-RateLimit-Used", strconv.Itoa(used))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+	h.Set("X-RateLimit-Resource", "core")
+	h.Set("X-GitHub-Request-Id", fmt.Sprintf("GHTEST:%d", s.id()))
+
+	if exceeded {
+		writeError(w, http.StatusForbidden, fmt.Sprintf("API rate limit exceeded for %s.", c.identity()))
+		return false
+	}
+
+	return true
+}
+
+func (s *Server) getRateLimit(w http.ResponseWriter, r *http.Request, c *caller) {
+	used := s.used[c.identity()]
+	rate := map[string]interface{}{
+		"limit":     s.rateLimit,
+		"used":      used,
+		"remaining": s.rateLimit - used,
+		"reset":     time.Now().Add(time.Hour).Unix(),
+		"resource":  "core",
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"resources": map[string]interface{}{"core": rate},
+		"rate":      rate,
+	})
+}
+
+func (s *Server) getApp(w http.ResponseWriter, r *http.Request, c *caller) {
+	writeJSON(w, http.StatusOK, c.app)
+}
+
+func (s *Server) listInstallations(w http.ResponseWriter, r *http.Request, c *caller) {
+	installations := []*Installation{}
+	for _, i := range s.installations {
+		if i.AppID == c.app.ID {
+			installations = append(installations, i)
+		}
+	}
+	sort.Slice(installations, func(i, j int) bool { return installations[i].ID < installations[j].ID })
+
+	writePage(w, r, s.perPage, len(installations), func(start, end int) interface{} {
+		return installations[start:end]
+	})
+}
+
+func (s *Server) createToken(w http.ResponseWriter, r *http.Request, c *caller) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	installation, ok := s.installations[id]
+	if !ok || installation.AppID != c.app.ID {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	t, expires := s.issueToken(installation)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"token":       t,
+		"expires_at":  expires,
+		"permissions": installation.Permissions,
+	})
+}
+
+func (s *Server) listRepositories(w http.ResponseWriter, r *http.Request, c *caller) {
+	repos := []*Repository{}
+	for _, name := range c.installation.repositories {
+		if repo, ok := s.repositories[name]; ok {
+			repos = append(repos, repo)
+		}
+	}
+
+	writePage(w, r, s.perPage, len(repos), func(start, end int) interface{} {
+		return map[string]interface{}{"total_count": len(repos), "repositories": repos[start:end]}
+	})
+}
+
+func (s *Server) getRepository(w http.ResponseWriter, r *http.Request, repo *Repository) {
+	writeJSON(w, http.StatusOK, repo)
+}
+
+func (s *Server) listIssues(w http.ResponseWriter, r *http.Request, repo *Repository) {
+	state := r.URL.Query().Get("state")
+	if state == "" {
+		state = "open"
+	}
+
+	issues := []*Issue{}
+	for _, issue := range repo.issues {
+		if state == "all" || issue.State == state {
+			issues = append(issues, issue)
+		}
+	}
+
+	writePage(w, r, s.perPage, len(issues), func(start, end int) interface{} {
+		return issues[start:end]
+	})
+}
+
+func (s *Server) createIssue(w http.ResponseWriter, r *http.Request, repo *Repository) {
+	var v struct {
+		Title string `json:"title"`
+		Body  string `json:"body"`
+	}
+	if !readJSON(w, r, &v) {
+		return
+	}
+	if v.Title == "" {
+		writeValidationError(w, "Issue", "title", "missing_field")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, s.addIssue(repo, v.Title, v.Body, bot()))
+}
+
+func (s *Server) getIssue(w http.ResponseWriter, r *http.Request, repo *Repository) {
+	issue, ok := findIssue(w, r, repo)
+	if ok {
+		writeJSON(w, http.StatusOK, issue)
+	}
+}
+
+func (s *Server) updateIssue(w http.ResponseWriter, r *http.Request, repo *Repository) {
+	issue, ok := findIssue(w, r, repo)
+	if !ok {
+		return
+	}
+
+	var v struct {
+		Title *string `json:"title"`
+		Body  *string `json:"body"`
+		State *string `json:"state"`
+	}
+	if !readJSON(w, r, &v) {
+		return
+	}
+	if v.State != nil && *v.State != "open" && *v.State != "closed" {
+		writeValidationError(w, "Issue", "state", "invalid")
+		return
+	}
+
+	if v.Title != nil {
+		issue.Title = *v.Title
+	}
+	if v.Body != nil {
+		issue.Body = *v.Body
+	}
+	if v.State != nil {
+		issue.State = *v.State
+	}
+	issue.UpdatedAt = time.Now().UTC()
+
+	writeJSON(w, http.StatusOK, issue)
+}
+
+func (s *Server) listComments(w http.ResponseWriter, r *http.Request, repo *Repository) {
+	issue, ok := findIssue(w, r, repo)
+	if !ok {
+		return
+	}
+
+	writePage(w, r, s.perPage, len(issue.comments), func(start, end int) interface{} {
+		return append([]*Comment{}, issue.comments[start:end]...)
+	})
+}
+
+func (s *Server) createComment(w http.ResponseWriter, r *http.Request, repo *Repository) {
+	issue, ok := findIssue(w, r, repo)
+	if !ok {
+		return
+	}
+
+	var v struct {
+		Body string `json:"body"`
+	}
+	if !readJSON(w, r, &v) {
+		return
+	}
+	if v.Body == "" {
+		writeValidationError(w, "IssueComment", "body", "missing_field")
+		return
+	}
+
+	comment := &Comment{ID: s.id(), Body: v.Body, User: bot(), CreatedAt: time.Now().UTC()}
+	issue.comments = append(issue.comments, comment)
+	issue.Comments = len(issue.comments)
+
+	writeJSON(w, http.StatusCreated, comment)
+}
+
+func (s *Server) createCheckRun(w http.ResponseWriter, r *http.Request, repo *Repository) {
+	var v CheckRun
+	if !readJSON(w, r, &v) {
+		return
+	}
+	if v.Name == "" || v.HeadSHA == "" {
+		writeValidationError(w, "CheckRun", "name", "missing_field")
+		return
+	}
+
+	run := &CheckRun{ID: s.id(), Name: v.Name, HeadSHA: v.HeadSHA, Status: "queued"}
+	if !applyCheckRun(w, run, &v) {
+		return
+	}
+	repo.checkRuns = append(repo.checkRuns, run)
+
+	writeJSON(w, http.StatusCreated, run)
+}
+
+func (s *Server) getCheckRun(w http.ResponseWriter, r *http.Request, repo *Repository) {
+	run, ok := findCheckRun(w, r, repo)
+	if ok {
+		writeJSON(w, http.StatusOK, run)
+	}
+}
+
+func (s *Server) updateCheckRun(w http.ResponseWriter, r *http.Request, repo *Repository) {
+	run, ok := findCheckRun(w, r, repo)
+	if !ok {
+		return
+	}
+
+	var v CheckRun
+	if !readJSON(w, r, &v) {
+		return
+	}
+	if v.Name != "" {
+		run.Name = v.Name
+	}
+	if applyCheckRun(w, run, &v) {
+		writeJSON(w, http.StatusOK, run)
+	}
+}
+
+// applyCheckRun applies the status and conclusion of v to run, completing it if a conclusion is given
+func applyCheckRun(w http.ResponseWriter, run *CheckRun, v *CheckRun) bool {
+	switch v.Status {
+	case "":
+	case "queued", "in_progress", "completed":
+		run.Status = v.Status
+	default:
+		writeValidationError(w, "CheckRun", "status", "invalid")
+		return false
+	}
+
+	now := time.Now().UTC()
+	if run.Status == "in_progress" && run.StartedAt == nil {
+		run.StartedAt = &now
+	}
+
+	if v.Conclusion != nil {
+		run.Status = "completed"
+		run.Conclusion = v.Conclusion
+	}
+	if run.Status == "completed" {
+		if run.Conclusion == nil {
+			writeValidationError(w, "CheckRun", "conclusion", "missing_field")
+			return false
+		}
+		if run.StartedAt == nil {
+			run.StartedAt = &now
+		}
+		run.CompletedAt = &now
+	}
+
+	return true
+}
+
+func findIssue(w http.ResponseWriter, r *http.Request, repo *Repository) (*Issue, bool) {
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err != nil || number < 1 || number > len(repo.issues) {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return nil, false
+	}
+	return repo.issues[number-1], true
+}
+
+func findCheckRun(w http.ResponseWriter, r *http.Request, repo *Repository) (*CheckRun, bool) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	for _, run := range repo.checkRuns {
+		if run.ID == id {
+			return run, true
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+	return nil, false
+}
+
+// writePage writes the page of items requested by the page and per_page parameters, with Link headers
+// page returns the body for the items between start and end
+func writePage(w http.ResponseWriter, r *http.Request, defaultPerPage int, total int, page func(start, end int) interface{}) {
+	query := r.URL.Query()
+
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = defaultPerPage
+	}
+	if perPage > 100 {
+		perPage = 100
+	}
+
+	current, err := strconv.Atoi(query.Get("page"))
+	if err != nil || current < 1 {
+		current = 1
+	}
+
+	last := (total + perPage - 1) / perPage
+	if last < 1 {
+		last = 1
+	}
+
+	link := func(p int, rel string) string {
+		u := url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path}
+		q := r.URL.Query()
+		q.Set("page", strconv.Itoa(p))
+		q.Set("per_page", strconv.Itoa(perPage))
+		u.RawQuery = q.Encode()
+		return fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel)
+	}
+
+	var links []string
+	if current < last {
+		links = append(links, link(current+1, "next"), link(last, "last"))
+	}
+	if current > 1 {
+		links = append(links, link(current-1, "prev"), link(1, "first"))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	start := (current - 1) * perPage
+	if start > total {
+		start = total
+	}
+	end := start + perPage
+	if end > total {
+		end = total
+	}
+
+	writeJSON(w, http.StatusOK, page(start, end))
+}
+
+// authorization returns the credentials of the Authorization header if it uses scheme
+func authorization(r *http.Request, scheme string) (string, bool) {
+	h := r.Header.Get("Authorization")
+	i := strings.Index(h, " ")
+	if i < 0 || !strings.EqualFold(h[:i], scheme) {
+		return "", false
+	}
+	return strings.TrimSpace(h[i+1:]), true
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"message":           message,
+		"documentation_url": "https://docs.github.com/rest",
+	})
+}
+
+func writeValidationError(w http.ResponseWriter, resource string, field string, code string) {
+	writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+		"message":           "Validation Failed",
+		"errors":            []map[string]string{{"resource": resource, "field": field, "code": code}},
+		"documentation_url": "https://docs.github.com/rest",
+	})
+}
+
+// bot is the account issues and comments created through the API are authored by
+func bot() Account {
+	return Account{Login: "githubtest[bot]", Type: "Bot"}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,294 @@
+// Package githubtest provides an in memory fake of Githubs API for tests
+// It serves apps, installations, repositories, issues, comments and check runs,
+// authenticating apps with JWTs and installations with the tokens it issues
+//
+//	server := githubtest.NewServer()
+//	defer server.Close()
+//
+//	server.AddApp(1, &key.PublicKey)
+//	server.AddRepository("weavc", "crusch")
+//	server.AddInstallation(1, 2, githubtest.Permissions{"issues": "write"}, "weavc/crusch")
+//
+//	auth, err := server.Client().NewInstallationAuth(1, 2, key)
+package githubtest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weavc/crusch"
+)
+
+// Permissions are the permissions granted to an installation i.e. {"issues": "write", "checks": "read"}
+// metadata:read is always granted
+type Permissions map[string]string
+
+// App is a Github App
+type App struct {
+	ID   int64  `json:"id"`
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+
+	key *rsa.PublicKey
+}
+
+// Installation is an installation of an App
+type Installation struct {
+	ID          int64       `json:"id"`
+	AppID       int64       `json:"app_id"`
+	Permissions Permissions `json:"permissions"`
+
+	repositories []string
+}
+
+// Account is the owner of a repository or author of an issue or comment
+type Account struct {
+	Login string `json:"login"`
+	Type  string `json:"type"`
+}
+
+// Repository is a repository
+type Repository struct {
+	ID       int64   `json:"id"`
+	Name     string  `json:"name"`
+	FullName string  `json:"full_name"`
+	Owner    Account `json:"owner"`
+	Private  bool    `json:"private"`
+
+	issues    []*Issue
+	checkRuns []*CheckRun
+}
+
+// Issue is an issue of a repository
+type Issue struct {
+	ID        int64     `json:"id"`
+	Number    int       `json:"number"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	State     string    `json:"state"`
+	User      Account   `json:"user"`
+	Comments  int       `json:"comments"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	comments []*Comment
+}
+
+// Comment is a comment on an issue
+type Comment struct {
+	ID        int64     `json:"id"`
+	Body      string    `json:"body"`
+	User      Account   `json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CheckRun is a check run of a commit
+type CheckRun struct {
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	HeadSHA     string     `json:"head_sha"`
+	Status      string     `json:"status"`
+	Conclusion  *string    `json:"conclusion"`
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+type token struct {
+	installation *Installation
+	expires      time.Time
+}
+
+// Server is a fake Github API server
+type Server struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	rateLimit     int
+	perPage       int
+	apps          map[int64]*App
+	installations map[int64]*Installation
+	repositories  map[string]*Repository
+	tokens        map[string]*token
+	used          map[string]int
+	nextID        int64
+}
+
+// NewServer starts a fake Github API server, it should be closed once finished with
+func NewServer() *Server {
+	s := &Server{
+		rateLimit:     5000,
+		perPage:       30,
+		apps:          map[int64]*App{},
+		installations: map[int64]*Installation{},
+		repositories:  map[string]*Repository{},
+		tokens:        map[string]*token{},
+		used:          map[string]int{},
+	}
+	s.Server = httptest.NewServer(s.routes())
+	return s
+}
+
+// Client returns a crusch Client making requests to the server
+func (s *Server) Client() *crusch.Client {
+	client := crusch.NewGithubClient(strings.TrimPrefix(s.URL, "http://"), "http")
+	client.SetHTTPClient(s.Server.Client())
+	return client
+}
+
+// SetRateLimit sets the number of requests each app and installation can make, 5000 by default
+// Rate limits never reset, once exhausted requests fail with 403 as they would on Github
+func (s *Server) SetRateLimit(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimit = limit
+}
+
+// SetPerPage sets the default page size of list endpoints, 30 by default
+func (s *Server) SetPerPage(perPage int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.perPage = perPage
+}
+
+// AddApp registers an app, JWTs for the app are validated with key
+func (s *Server) AddApp(id int64, key *rsa.PublicKey) *App {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app := &App{ID: id, Slug: fmt.Sprintf("app-%d", id), Name: fmt.Sprintf("App %d", id), key: key}
+	s.apps[id] = app
+	return app
+}
+
+// AddInstallation installs an app with the given permissions on the repositories, named "owner/repo"
+func (s *Server) AddInstallation(appID int64, id int64, permissions Permissions, repositories ...string) *Installation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := Permissions{"metadata": "read"}
+	for k, v := range permissions {
+		p[k] = v
+	}
+
+	installation := &Installation{ID: id, AppID: appID, Permissions: p, repositories: repositories}
+	s.installations[id] = installation
+	return installation
+}
+
+// AddRepository creates a repository
+func (s *Server) AddRepository(owner string, name string) *Repository {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repo := &Repository{
+		ID:       s.id(),
+		Name:     name,
+		FullName: fmt.Sprintf("%s/%s", owner, name),
+		Owner:    Account{Login: owner, Type: "User"},
+	}
+	s.repositories[repo.FullName] = repo
+	return repo
+}
+
+// AddIssue creates an open issue on the repository, which must have been added
+func (s *Server) AddIssue(owner string, repo string, title string, body string) *Issue {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.repositories[fmt.Sprintf("%s/%s", owner, repo)]
+	if !ok {
+		panic(fmt.Sprintf("githubtest: repository %s/%s has not been added", owner, repo))
+	}
+	return s.addIssue(r, title, body, Account{Login: owner, Type: "User"})
+}
+
+// Issues returns a copy of the issues of the repository
+func (s *Server) Issues(owner string, repo string) []Issue {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.repositories[fmt.Sprintf("%s/%s", owner, repo)]
+	if !ok {
+		return nil
+	}
+
+	issues := make([]Issue, len(r.issues))
+	for i, issue := range r.issues {
+		issues[i] = *issue
+	}
+	return issues
+}
+
+// Comments returns a copy of the comments on an issue of the repository
+func (s *Server) Comments(owner string, repo string, number int) []Comment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.repositories[fmt.Sprintf("%s/%s", owner, repo)]
+	if !ok || number < 1 || number > len(r.issues) {
+		return nil
+	}
+
+	comments := make([]Comment, len(r.issues[number-1].comments))
+	for i, comment := range r.issues[number-1].comments {
+		comments[i] = *comment
+	}
+	return comments
+}
+
+// CheckRuns returns a copy of the check runs of the repository
+func (s *Server) CheckRuns(owner string, repo string) []CheckRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.repositories[fmt.Sprintf("%s/%s", owner, repo)]
+	if !ok {
+		return nil
+	}
+
+	runs := make([]CheckRun, len(r.checkRuns))
+	for i, run := range r.checkRuns {
+		runs[i] = *run
+	}
+	return runs
+}
+
+// addIssue adds an issue to r, mu must be held
+func (s *Server) addIssue(r *Repository, title string, body string, user Account) *Issue {
+	now := time.Now().UTC()
+	issue := &Issue{
+		ID:        s.id(),
+		Number:    len(r.issues) + 1,
+		Title:     title,
+		Body:      body,
+		State:     "open",
+		User:      user,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.issues = append(r.issues, issue)
+	return issue
+}
+
+// issueToken creates an installation access token, mu must be held
+func (s *Server) issueToken(installation *Installation) (string, time.Time) {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	t := fmt.Sprintf("ghs_%s", hex.EncodeToString(b))
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	s.tokens[t] = &token{installation: installation, expires: expires}
+	return t, expires
+}
+
+// id returns the next id, mu must be held
+func (s *Server) id() int64 {
+	s.nextID++
+	return s.nextID
+}
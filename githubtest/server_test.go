@@ -0,0 +1,150 @@
+package githubtest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/weavc/crusch"
+)
+
+func setupServer(t *testing.T, permissions Permissions) (*Server, *crusch.Client, *crusch.InstallationAuth, *rsa.PrivateKey) {
+	key, err := crusch.RSAPrivateKeyFromPEMFile("../random_key.pem")
+	if err != nil {
+		t.Fatalf("setup: unexpected %v", err)
+	}
+
+	server := NewServer()
+	t.Cleanup(server.Close)
+
+	server.AddApp(1, &key.PublicKey)
+	server.AddRepository("weavc", "crusch")
+	server.AddRepository("weavc", "private")
+	server.AddInstallation(1, 2, permissions, "weavc/crusch")
+
+	client := server.Client()
+	auth, _ := client.NewInstallationAuth(1, 2, key)
+	return server, client, auth, key
+}
+
+func TestInstallationFlow(t *testing.T) {
+	server, client, auth, _ := setupServer(t, Permissions{"issues": "write", "checks": "write"})
+
+	var issue Issue
+	res, err := client.Post(auth, "repos/weavc/crusch/issues", map[string]string{"title": "crusch", "body": "fake"}, &issue)
+	if err != nil || res.StatusCode != http.StatusCreated || issue.Number != 1 || issue.State != "open" {
+		t.Fatalf("create issue: returned %+v, %v", issue, err)
+	}
+
+	if res.Rate.Limit != 5000 || res.Rate.Remaining != 4999 || res.RequestID == "" {
+		t.Errorf("create issue: rate %+v, request id %s", res.Rate, res.RequestID)
+	}
+
+	var comment Comment
+	_, err = client.Post(auth, "repos/weavc/crusch/issues/1/comments", map[string]string{"body": "hello"}, &comment)
+	if err != nil || comment.Body != "hello" {
+		t.Errorf("create comment: returned %+v, %v", comment, err)
+	}
+
+	_, err = client.Patch(auth, "repos/weavc/crusch/issues/1", map[string]string{"state": "closed"}, &issue)
+	if err != nil || issue.State != "closed" || issue.Comments != 1 {
+		t.Errorf("close issue: returned %+v, %v", issue, err)
+	}
+
+	var run CheckRun
+	_, err = client.Post(auth, "repos/weavc/crusch/check-runs", map[string]string{"name": "test", "head_sha": "abc"}, &run)
+	if err != nil || run.Status != "queued" {
+		t.Fatalf("create check run: returned %+v, %v", run, err)
+	}
+
+	_, err = client.Patch(auth, "repos/weavc/crusch/check-runs/{id}", map[string]string{"conclusion": "success"}, &run,
+		crusch.WithPathParams(map[string]string{"id": strconv.FormatInt(run.ID, 10)}))
+	if err != nil || run.Status != "completed" || run.CompletedAt == nil {
+		t.Errorf("complete check run: returned %+v, %v", run, err)
+	}
+
+	runs := server.CheckRuns("weavc", "crusch")
+	if len(runs) != 1 || server.Comments("weavc", "crusch", 1)[0].Body != "hello" || server.Issues("weavc", "crusch")[0].State != "closed" {
+		t.Errorf("server state: check runs %+v, issues %+v", runs, server.Issues("weavc", "crusch"))
+	}
+
+	_, err = client.Get(auth, "repos/weavc/private", nil, nil)
+	if !crusch.IsNotFound(err) {
+		t.Errorf("uninstalled repository: returned %v want not found", err)
+	}
+
+	_, err = client.Get(crusch.AuthorizerFunc(func() (string, error) { return "token ghs_invalid", nil }), "repos/weavc/crusch", nil, nil)
+	if !isUnauthorized(err) {
+		t.Errorf("invalid token: returned %v want unauthorized", err)
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	_, client, auth, _ := setupServer(t, Permissions{"issues": "read"})
+
+	_, err := client.Get(auth, "repos/weavc/crusch/issues", nil, nil)
+	if err != nil {
+		t.Errorf("read issues: unexpected %v", err)
+	}
+
+	_, err = client.Post(auth, "repos/weavc/crusch/issues", map[string]string{"title": "crusch"}, nil)
+	if !crusch.IsForbidden(err) {
+		t.Errorf("create issue without write: returned %v want forbidden", err)
+	}
+
+	_, err = client.Post(auth, "repos/weavc/crusch/check-runs", map[string]string{"name": "test", "head_sha": "abc"}, nil)
+	if !crusch.IsForbidden(err) {
+		t.Errorf("create check run without checks: returned %v want forbidden", err)
+	}
+}
+
+func TestAppJWT(t *testing.T) {
+	_, client, _, key := setupServer(t, nil)
+
+	app, _ := crusch.NewApplicationAuth(1, key)
+	var v App
+	_, err := client.Get(app, "app", nil, &v)
+	if err != nil || v.ID != 1 {
+		t.Errorf("app: returned %+v, %v", v, err)
+	}
+
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	forged, _ := crusch.NewApplicationAuth(1, other)
+	_, err = client.Get(forged, "app", nil, nil)
+	if !isUnauthorized(err) {
+		t.Errorf("app with wrong key: returned %v want unauthorized", err)
+	}
+
+	_, err = client.Post(app, "app/installations/3/access_tokens", nil, nil)
+	if !crusch.IsNotFound(err) {
+		t.Errorf("token for unknown installation: returned %v want not found", err)
+	}
+}
+
+func TestPaginationAndRateLimit(t *testing.T) {
+	server, client, auth, _ := setupServer(t, Permissions{"issues": "read"})
+	for i := 0; i < 5; i++ {
+		server.AddIssue("weavc", "crusch", "issue", "")
+	}
+
+	p := client.NewPaginator(auth, "repos/weavc/crusch/issues", nil)
+	p.PerPage = 2
+	issues, err := crusch.Collect[Issue](p)
+	if err != nil || len(issues) != 5 || issues[4].Number != 5 {
+		t.Fatalf("paginate issues: returned %d issues, %v", len(issues), err)
+	}
+
+	server.SetRateLimit(3)
+	_, err = client.Get(auth, "repos/weavc/crusch", nil, nil)
+	if !crusch.IsRateLimited(err) {
+		t.Errorf("exhausted rate limit: returned %v want rate limited", err)
+	}
+}
+
+func isUnauthorized(err error) bool {
+	var e *crusch.ErrorResponse
+	return errors.As(err, &e) && e.StatusCode == http.StatusUnauthorized
+}
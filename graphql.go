@@ -0,0 +1,108 @@
+package crusch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// GraphQLError is a single error returned by Githubs GraphQL API
+// https://docs.github.com/en/graphql/guides/forming-calls-with-graphql
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Type       string                 `json:"type"`
+	Path       []interface{}          `json:"path"`
+	Locations  []GraphQLLocation      `json:"locations"`
+	Extensions map[string]interface{} `json:"extensions"`
+}
+
+// GraphQLLocation is the position in the query a GraphQLError refers to
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (e GraphQLError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Message)
+
+	if len(e.Path) > 0 {
+		path := make([]string, len(e.Path))
+		for i, p := range e.Path {
+			path[i] = fmt.Sprint(p)
+		}
+		fmt.Fprintf(&b, " (path %s)", strings.Join(path, "."))
+	}
+
+	for _, l := range e.Locations {
+		fmt.Fprintf(&b, " (line %d, column %d)", l.Line, l.Column)
+	}
+
+	return b.String()
+}
+
+// GraphQLErrors are the errors returned alongside a GraphQL response
+// Any data returned with the errors is still bound
+type GraphQLErrors []GraphQLError
+
+func (e GraphQLErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("graphql: %s", strings.Join(messages, "; "))
+}
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors GraphQLErrors   `json:"errors"`
+}
+
+// GraphQL executes the query against Githubs GraphQL API, binding the data of the response to v
+// Errors returned by the API are returned as GraphQLErrors
+// https://docs.github.com/en/graphql
+func (c *Client) GraphQL(authorizer Authorizer, query string, variables map[string]interface{}, v interface{}, options ...RequestOption) (*Response, error) {
+	return c.GraphQLContext(context.Background(), authorizer, query, variables, v, options...)
+}
+
+// GraphQLContext executes the query against Githubs GraphQL API using the given context
+// The data of the response is bound to v, errors returned by the API are returned as GraphQLErrors
+func (c *Client) GraphQLContext(ctx context.Context, authorizer Authorizer, query string, variables map[string]interface{}, v interface{}, options ...RequestOption) (*Response, error) {
+	body := &graphQLRequest{Query: query, Variables: variables}
+
+	var r graphQLResponse
+	res, err := c.doWithBody(ctx, http.MethodPost, authorizer, c.graphQLURL(), body, &r, options)
+	if err != nil {
+		return res, err
+	}
+
+	if v != nil && len(r.Data) > 0 && string(r.Data) != "null" {
+		err = json.Unmarshal(r.Data, v)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	if len(r.Errors) > 0 {
+		return res, r.Errors
+	}
+
+	return res, nil
+}
+
+// graphQLURL returns the url of the GraphQL endpoint for the client
+// Github Enterprise Server serves the v3 API from /api/v3 and GraphQL from /api/graphql
+func (c *Client) graphQLURL() string {
+	u := strings.TrimRight(c.URL, "/")
+	if strings.HasSuffix(u, "/api/v3") {
+		return fmt.Sprintf("%s://%s/api/graphql", c.Protocol, strings.TrimSuffix(u, "/api/v3"))
+	}
+	return fmt.Sprintf("%s://%s/graphql", c.Protocol, u)
+}
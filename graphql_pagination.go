@@ -0,0 +1,188 @@
+package crusch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ErrStopPagination can be returned, or wrapped, by a GraphQLPaginate callback to stop paginating early
+// GraphQLPaginate will return nil instead of the error
+var ErrStopPagination = errors.New("stop pagination")
+
+type graphQLConnection struct {
+	Nodes    []json.RawMessage `json:"nodes"`
+	Edges    []json.RawMessage `json:"edges"`
+	PageInfo *struct {
+		HasNextPage bool   `json:"hasNextPage"`
+		EndCursor   string `json:"endCursor"`
+	} `json:"pageInfo"`
+}
+
+type graphQLRateLimit struct {
+	RateLimit *struct {
+		Limit     int       `json:"limit"`
+		Cost      int       `json:"cost"`
+		Remaining int       `json:"remaining"`
+		Used      int       `json:"used"`
+		ResetAt   time.Time `json:"resetAt"`
+	} `json:"rateLimit"`
+}
+
+// GraphQLPaginate executes a query repeatedly, following the cursor of the connection at path
+// The query must accept an $after variable and select pageInfo { hasNextPage endCursor } on the connection
+// path is the dot separated location of the connection in the response data i.e. "repository.issues"
+// fn is called with each node of the connection, or each edge if the connection has no nodes
+// If the query selects rateLimit { cost remaining resetAt }, pagination waits for the reset when
+// the remaining points will not cover the cost of the next page
+func (c *Client) GraphQLPaginate(authorizer Authorizer, query string, variables map[string]interface{}, path string, fn func(node json.RawMessage) error, options ...RequestOption) error {
+	return c.GraphQLPaginateContext(context.Background(), authorizer, query, variables, path, fn, options...)
+}
+
+// GraphQLPaginateContext is GraphQLPaginate using the given context
+func (c *Client) GraphQLPaginateContext(ctx context.Context, authorizer Authorizer, query string, variables map[string]interface{}, path string, fn func(node json.RawMessage) error, options ...RequestOption) error {
+	vars := make(map[string]interface{}, len(variables)+1)
+	for k, v := range variables {
+		vars[k] = v
+	}
+
+	for {
+		var data json.RawMessage
+		_, err := c.GraphQLContext(ctx, authorizer, query, vars, &data, options...)
+		if err != nil {
+			return err
+		}
+
+		conn, err := graphQLConnectionAt(data, path)
+		if err != nil {
+			return err
+		}
+
+		items := conn.Nodes
+		if items == nil {
+			items = conn.Edges
+		}
+
+		for _, item := range items {
+			err = fn(item)
+			if errors.Is(err, ErrStopPagination) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if conn.PageInfo == nil || !conn.PageInfo.HasNextPage {
+			return nil
+		}
+		// a missing or repeated cursor would request the same page forever
+		cursor := conn.PageInfo.EndCursor
+		if cursor == "" || cursor == vars["after"] {
+			return fmt.Errorf("connection at %s has a next page but its endCursor %q does not advance", path, cursor)
+		}
+		vars["after"] = cursor
+
+		err = c.graphQLRateLimit(ctx, authorizer, data)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// GraphQLCollect paginates the connection at path like GraphQLPaginate, collecting every node into v
+// v must be a pointer to a slice
+func (c *Client) GraphQLCollect(authorizer Authorizer, query string, variables map[string]interface{}, path string, v interface{}, options ...RequestOption) error {
+	return c.GraphQLCollectContext(context.Background(), authorizer, query, variables, path, v, options...)
+}
+
+// GraphQLCollectContext is GraphQLCollect using the given context
+func (c *Client) GraphQLCollectContext(ctx context.Context, authorizer Authorizer, query string, variables map[string]interface{}, path string, v interface{}, options ...RequestOption) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("v must be a non nil pointer to a slice")
+	}
+
+	slice := rv.Elem()
+	return c.GraphQLPaginateContext(ctx, authorizer, query, variables, path, func(node json.RawMessage) error {
+		item := reflect.New(slice.Type().Elem())
+		err := json.Unmarshal(node, item.Interface())
+		if err != nil {
+			return err
+		}
+
+		slice.Set(reflect.Append(slice, item.Elem()))
+		return nil
+	}, options...)
+}
+
+// graphQLConnectionAt finds and decodes the connection at the dot separated path in data
+func graphQLConnectionAt(data json.RawMessage, path string) (*graphQLConnection, error) {
+	current := data
+	for _, key := range strings.Split(path, ".") {
+		var fields map[string]json.RawMessage
+		err := json.Unmarshal(current, &fields)
+		if err != nil || fields == nil {
+			return nil, fmt.Errorf("no connection found at %s", path)
+		}
+
+		var ok bool
+		current, ok = fields[key]
+		if !ok {
+			return nil, fmt.Errorf("no connection found at %s", path)
+		}
+	}
+
+	conn := &graphQLConnection{}
+	err := json.Unmarshal(current, conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode connection at %s: %v", path, err)
+	}
+
+	return conn, nil
+}
+
+// graphQLRateLimit records the rateLimit selected by the query, if any,
+// waiting for it to reset if the remaining points will not cover the cost of the next query
+func (c *Client) graphQLRateLimit(ctx context.Context, authorizer Authorizer, data json.RawMessage) error {
+	var r graphQLRateLimit
+	if json.Unmarshal(data, &r) != nil || r.RateLimit == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	var rate Rate
+	if key, ok := rateKey(authorizer, ResourceGraphQL); ok {
+		rate = c.rates[key]
+	}
+	if r.RateLimit.Limit > 0 {
+		rate.Limit = r.RateLimit.Limit
+	}
+	if r.RateLimit.Used > 0 {
+		rate.Used = r.RateLimit.Used
+	}
+	rate.Remaining = r.RateLimit.Remaining
+	rate.Reset = r.RateLimit.ResetAt
+	rate.Resource = ResourceGraphQL
+	c.setRate(authorizer, ResourceGraphQL, rate)
+	c.mu.Unlock()
+
+	if id, ok := identity(authorizer); ok {
+		c.getMetrics().SetRateLimit(id, ResourceGraphQL, rate.Remaining, rate.Limit)
+	}
+
+	if r.RateLimit.Remaining >= r.RateLimit.Cost {
+		return nil
+	}
+
+	d := time.Until(r.RateLimit.ResetAt)
+	if d <= 0 {
+		return nil
+	}
+
+	return sleep(ctx, d)
+}
@@ -0,0 +1,124 @@
+package crusch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const issuesQuery = `query($after: String) {
+	rateLimit { cost remaining resetAt }
+	repository(owner: "weavc", name: "crusch") {
+		issues(first: 2, after: $after) { nodes { number } pageInfo { hasNextPage endCursor } }
+	}
+}`
+
+type issueNode struct {
+	Number int `json:"number"`
+}
+
+func TestGraphQLCollect(t *testing.T) {
+	client := setupFakeClient(&fakeTransport{handler: issuesConnection(3, 100)})
+
+	var v []issueNode
+	err := client.GraphQLCollect(setupAuth(), issuesQuery, nil, "repository.issues", &v)
+	if err != nil {
+		t.Fatalf("collect: unexpected %v", err)
+	}
+
+	want := []issueNode{{1}, {2}, {3}, {4}, {5}, {6}}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("collect: returned %v want %v", v, want)
+	}
+
+	rate, ok := client.RateLimit(setupAuth(), ResourceGraphQL)
+	if !ok || rate.Remaining != 100 {
+		t.Errorf("collect: graphql rate limit %+v, recorded %v", rate, ok)
+	}
+
+	err = client.GraphQLCollect(setupAuth(), issuesQuery, nil, "repository.missing", &v)
+	if err == nil {
+		t.Errorf("collect, bad path: unexpected nil error")
+	}
+}
+
+func TestGraphQLPaginateStop(t *testing.T) {
+	rt := &fakeTransport{handler: issuesConnection(3, 100)}
+	client := setupFakeClient(rt)
+
+	count := 0
+	err := client.GraphQLPaginate(setupAuth(), issuesQuery, nil, "repository.issues", func(node json.RawMessage) error {
+		count++
+		if count == 3 {
+			return fmt.Errorf("enough issues: %w", ErrStopPagination)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("stop: unexpected %v", err)
+	}
+
+	if count != 3 || rt.Calls() != 2 {
+		t.Errorf("stop: %d nodes from %d requests want 3 from 2", count, rt.Calls())
+	}
+}
+
+func TestGraphQLPaginateCost(t *testing.T) {
+	rt := &fakeTransport{handler: issuesConnection(3, 0)}
+	client := setupFakeClient(rt)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := client.GraphQLPaginateContext(ctx, setupAuth(), issuesQuery, nil, "repository.issues", func(node json.RawMessage) error {
+		return nil
+	})
+	if err != context.DeadlineExceeded || rt.Calls() != 1 {
+		t.Errorf("cost: returned %v after %d requests", err, rt.Calls())
+	}
+}
+
+func TestGraphQLPaginateCursor(t *testing.T) {
+	for _, cursor := range []string{"", "cursor1"} {
+		rt := &fakeTransport{}
+		rt.handler = func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(w, `{"data": {"repository": {"issues": {
+				"nodes": [{"number": %d}],
+				"pageInfo": {"hasNextPage": true, "endCursor": %q}
+			}}}}`, rt.Calls(), cursor)
+		}
+		client := setupFakeClient(rt)
+
+		var v []issueNode
+		err := client.GraphQLCollect(setupAuth(), issuesQuery, nil, "repository.issues", &v)
+		if err == nil || rt.Calls() > 2 {
+			t.Errorf("cursor %q: returned %v after %d requests", cursor, err, rt.Calls())
+		}
+	}
+}
+
+// issuesConnection serves pages of an issues connection with two nodes each, using the $after cursor
+func issuesConnection(pages int, remaining int) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var body graphQLRequest
+		json.NewDecoder(req.Body).Decode(&body)
+
+		page := 1
+		if after, ok := body.Variables["after"].(string); ok {
+			fmt.Sscanf(after, "cursor%d", &page)
+			page++
+		}
+
+		fmt.Fprintf(w, `{"data": {
+			"rateLimit": {"cost": 1, "remaining": %d, "resetAt": %q},
+			"repository": {"issues": {
+				"nodes": [{"number": %d}, {"number": %d}],
+				"pageInfo": {"hasNextPage": %v, "endCursor": "cursor%d"}
+			}}
+		}}`, remaining, time.Now().Add(time.Hour).Format(time.RFC3339), page*2-1, page*2, page < pages, page)
+	}
+}
@@ -0,0 +1,84 @@
+package crusch
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGraphQL(t *testing.T) {
+	var sent graphQLRequest
+	rt := &fakeTransport{handler: func(w http.ResponseWriter, req *http.Request) {
+		json.NewDecoder(req.Body).Decode(&sent)
+		w.Write([]byte(`{"data": {"viewer": {"login": "weavc"}}}`))
+	}}
+	client := setupFakeClient(rt)
+
+	var v struct {
+		Viewer struct {
+			Login string `json:"login"`
+		} `json:"viewer"`
+	}
+
+	_, err := client.GraphQL(setupAuth(), "query($n: Int!) { viewer { login } }", map[string]interface{}{"n": 1}, &v)
+	if err != nil {
+		t.Fatalf("valid graphql: unexpected %v", err)
+	}
+
+	if v.Viewer.Login != "weavc" {
+		t.Errorf("valid graphql: returned %v want %s", v.Viewer.Login, "weavc")
+	}
+
+	req := rt.Request()
+	if req.URL.Path != "/graphql" || req.Method != http.MethodPost {
+		t.Errorf("valid graphql: sent %s %s want POST /graphql", req.Method, req.URL.Path)
+	}
+
+	if sent.Query != "query($n: Int!) { viewer { login } }" || sent.Variables["n"] != float64(1) {
+		t.Errorf("valid graphql: sent %+v", sent)
+	}
+
+	if auth := req.Header.Get("Authorization"); auth != "bearer randombearertokenexample" {
+		t.Errorf("valid graphql: authorization %s", auth)
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	client := setupFakeClient(&fakeTransport{body: `{"data": {"viewer": {"login": "weavc"}, "repository": null}, "errors": [` +
+		`{"type": "NOT_FOUND", "path": ["repository"], "locations": [{"line": 1, "column": 22}],` +
+		` "message": "Could not resolve to a Repository with the name 'weavc/missing'."}]}`})
+
+	var v map[string]interface{}
+	_, err := client.GraphQL(setupAuth(), "{ viewer { login } repository(owner: \"weavc\", name: \"missing\") { id } }", nil, &v)
+
+	var gerr GraphQLErrors
+	if !errors.As(err, &gerr) || len(gerr) != 1 {
+		t.Fatalf("graphql errors: returned %v want GraphQLErrors", err)
+	}
+
+	if gerr[0].Type != "NOT_FOUND" || gerr[0].Path[0] != "repository" || gerr[0].Locations[0].Column != 22 {
+		t.Errorf("graphql errors: returned %+v", gerr[0])
+	}
+
+	if !strings.Contains(err.Error(), "path repository") || !strings.Contains(err.Error(), "line 1, column 22") {
+		t.Errorf("graphql errors: message %q", err.Error())
+	}
+
+	if v["viewer"] == nil {
+		t.Errorf("graphql errors: partial data was not bound")
+	}
+}
+
+func TestGraphQLURL(t *testing.T) {
+	c := NewGithubClient("api.github.com", "https")
+	if u := c.graphQLURL(); u != "https://api.github.com/graphql" {
+		t.Errorf("github graphql url: returned %s", u)
+	}
+
+	c = NewGithubClient("github.example.com/api/v3", "https")
+	if u := c.graphQLURL(); u != "https://github.example.com/api/graphql" {
+		t.Errorf("enterprise graphql url: returned %s", u)
+	}
+}
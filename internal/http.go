@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"reflect"
 
 	"github.com/google/go-querystring/query"
@@ -13,6 +14,8 @@ func ParseQuery(params interface{}) (string, error) {
 	switch v := params.(type) {
 	case string:
 		return v, nil
+	case url.Values:
+		return v.Encode(), nil
 	case nil:
 		return "", nil
 	default:
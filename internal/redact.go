@@ -0,0 +1,30 @@
+package internal
+
+import (
+	"regexp"
+)
+
+type redaction struct {
+	re *regexp.Regexp
+	// replacement is expanded as in regexp.ReplaceAllString, keeping the name of the value being redacted
+	replacement string
+}
+
+var redactions = []redaction{
+	// Authorization header values, only in a header context so prose such as "the token expired" is left alone
+	{regexp.MustCompile(`(?i)(\bauthorization["']?\s*[:=]\s*[\["']?\s*(?:bearer|token|basic)\s+)[A-Za-z0-9_\-\.=+/]+`), "${1}[REDACTED]"},
+	// installation, user, oauth and refresh tokens, fine grained personal access tokens
+	{regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9_]+|github_pat_[A-Za-z0-9_]+)`), "[REDACTED]"},
+	// JWTs, such as those used to authenticate as an application
+	{regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`), "[REDACTED]"},
+	// OAuth codes, secrets and tokens in querystrings and form bodies
+	{regexp.MustCompile(`(?i)(\b(?:code|client_secret|access_token|refresh_token|token)=)[^&\s"]+`), "${1}[REDACTED]"},
+}
+
+// Redact removes tokens, JWTs and OAuth codes from s
+func Redact(s string) string {
+	for _, r := range redactions {
+		s = r.re.ReplaceAllString(s, r.replacement)
+	}
+	return s
+}
@@ -0,0 +1,91 @@
+package crusch
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/weavc/crusch/internal"
+)
+
+// SetLogger sets the logger used to log requests made by the client, nil (default) disables logging
+// Each request is logged once it completes with its method, route, status, duration, request id and remaining rate limit
+// Failed requests are logged at LevelError, others at LevelInfo and retries at LevelDebug
+// Authorization headers are never logged, tokens, JWTs and OAuth codes are redacted from errors
+func (c *Client) SetLogger(logger *slog.Logger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logger = logger
+}
+
+// WithLogger returns a clone of the client using the given logger
+func (c *Client) WithLogger(logger *slog.Logger) *Client {
+	clone := c.Clone()
+	clone.SetLogger(logger)
+	return clone
+}
+
+func (c *Client) getLogger() *slog.Logger {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.logger
+}
+
+// logRequest logs the completed request if the client has a logger
+func (c *Client) logRequest(req *Request, res *Response, err error, duration time.Duration) {
+	logger := c.getLogger()
+	if logger == nil {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("host", req.URL.Host),
+		slog.String("route", req.Route),
+		slog.String("path", req.URL.Path),
+		slog.Duration("duration", duration),
+	}
+
+	if res != nil && res.Response != nil {
+		attrs = append(attrs, slog.Int("status", res.StatusCode))
+		if res.RequestID != "" {
+			attrs = append(attrs, slog.String("request_id", res.RequestID))
+		}
+		if res.Rate.Limit > 0 {
+			attrs = append(attrs, slog.Int("rate_remaining", res.Rate.Remaining))
+		}
+	}
+
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.String("error", internal.Redact(err.Error())))
+
+		var retryErr *RetryError
+		if errors.As(err, &retryErr) {
+			attrs = append(attrs, slog.Int("attempts", retryErr.Attempts))
+		}
+	}
+
+	logger.LogAttrs(req.Context(), level, "github request", attrs...)
+}
+
+// logRetry logs a request that is about to be retried after delay
+func (c *Client) logRetry(ctx context.Context, route string, attempt int, delay time.Duration, err error) {
+	logger := c.getLogger()
+	if logger == nil {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("route", route),
+		slog.Int("attempt", attempt),
+		slog.Duration("delay", delay),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", internal.Redact(err.Error())))
+	}
+
+	logger.LogAttrs(ctx, slog.LevelDebug, "retrying github request", attrs...)
+}
@@ -0,0 +1,95 @@
+package crusch
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/weavc/crusch/internal"
+)
+
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	header := http.Header{}
+	header.Set("X-GitHub-Request-Id", "ABCD:1234")
+	header.Set("X-RateLimit-Limit", "5000")
+	header.Set("X-RateLimit-Remaining", "4999")
+	client := setupFakeClient(&fakeTransport{header: header, body: `{"token": "ghs_secrettoken"}`})
+	client.SetLogger(logger)
+
+	auth, _ := client.NewInstallationAuth(1, 2, getKey())
+	_, err := client.Get(auth, "repos/{owner}/{repo}", "code=oauthcode", nil,
+		WithPathParams(map[string]string{"owner": "weavc", "repo": "crusch"}))
+	if err != nil {
+		t.Fatalf("logging: unexpected %v", err)
+	}
+
+	for _, secret := range []string{"ghs_secrettoken", "oauthcode", "eyJ"} {
+		if strings.Contains(buf.String(), secret) {
+			t.Errorf("logging: logged %s\n%s", secret, buf.String())
+		}
+	}
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		json.Unmarshal([]byte(line), &record)
+		records = append(records, record)
+	}
+
+	// the access token request, the token refresh then the request itself
+	if len(records) != 3 {
+		t.Fatalf("logging: logged %d records want 3\n%s", len(records), buf.String())
+	}
+
+	if records[1]["msg"] != "github installation token refreshed" || records[1]["installation_id"] != float64(2) {
+		t.Errorf("logging: token refresh logged %v", records[1])
+	}
+
+	r := records[2]
+	if r["method"] != "GET" || r["route"] != "/repos/{owner}/{repo}" || r["status"] != float64(200) ||
+		r["request_id"] != "ABCD:1234" || r["rate_remaining"] != float64(4999) || r["level"] != "INFO" {
+		t.Errorf("logging: request logged %v", r)
+	}
+
+	buf.Reset()
+	failing := setupFakeClient(&fakeTransport{status: 404, body: `{"message": "Not Found"}`})
+	failing.SetLogger(logger)
+	failing.Get(setupAuth(), "user?access_token=gho_secret", nil, nil)
+
+	if !strings.Contains(buf.String(), `"level":"ERROR"`) || strings.Contains(buf.String(), "gho_secret") {
+		t.Errorf("logging failure: logged %s", buf.String())
+	}
+
+	// requests without path parameters keep their path out of the route
+	if !strings.Contains(buf.String(), `"route":"unknown","path":"/user"`) {
+		t.Errorf("logging failure: logged route %s", buf.String())
+	}
+}
+
+func TestRedact(t *testing.T) {
+	cases := map[string]string{
+		"Authorization: token ghs_abc123":                   "Authorization: token [REDACTED]",
+		"bearer eyJhbGciOiJSUzI1NiJ9.eyJpc3MiOjF9.c2lnbmF0": "bearer [REDACTED]",
+		"jwt eyJhbGciOiJSUzI1NiJ9.eyJpc3MiOjF9.c2lnbmF0":    "jwt [REDACTED]",
+		"POST /login/oauth/access_token?code=abc&state=1":   "POST /login/oauth/access_token?code=[REDACTED]&state=1",
+		"client_secret=shh&client_id=1":                     "client_secret=[REDACTED]&client_id=1",
+		"using github_pat_11ABC and ghp_xyz":                "using [REDACTED] and [REDACTED]",
+		"404 Not Found":                                     "404 Not Found",
+		"Authorization: Bearer v1.abc123def":                "Authorization: Bearer [REDACTED]",
+		"map[Authorization:[token v1.abc123def]]":           "map[Authorization:[token [REDACTED]]]",
+		"401 the token expired, basic auth is disabled":     "401 the token expired, basic auth is disabled",
+		"Bad credentials: token has been revoked":           "Bad credentials: token has been revoked",
+	}
+
+	for in, want := range cases {
+		if got := internal.Redact(in); got != want {
+			t.Errorf("redact %s: returned %s want %s", in, got, want)
+		}
+	}
+}
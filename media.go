@@ -0,0 +1,32 @@
+package crusch
+
+import "context"
+
+// Media types Github can respond with, used in the Accept header
+// https://docs.github.com/en/rest/overview/media-types
+const (
+	MediaTypeJSON     = "application/vnd.github+json"
+	MediaTypeRaw      = "application/vnd.github.raw"
+	MediaTypeHTML     = "application/vnd.github.html"
+	MediaTypeDiff     = "application/vnd.github.diff"
+	MediaTypePatch    = "application/vnd.github.patch"
+	MediaTypeSHA      = "application/vnd.github.sha"
+	MediaTypeRawJSON  = "application/vnd.github.raw+json"
+	MediaTypeTextJSON = "application/vnd.github.text+json"
+	MediaTypeHTMLJSON = "application/vnd.github.html+json"
+	MediaTypeFullJSON = "application/vnd.github.full+json"
+)
+
+// GetMedia makes GET requests accepting the given media type instead of the clients Accept header
+// For non JSON media types (i.e. MediaTypeDiff, MediaTypeRaw) v should be a *[]byte, *string or io.Writer
+// For JSON media types (i.e. MediaTypeHTMLJSON) the response is bound to v as with Get
+func (c *Client) GetMedia(authorizer Authorizer, uri string, params interface{}, mediaType string, v interface{}, options ...RequestOption) (*Response, error) {
+	return c.GetMediaContext(context.Background(), authorizer, uri, params, mediaType, v, options...)
+}
+
+// GetMediaContext is GetMedia using the given context
+func (c *Client) GetMediaContext(ctx context.Context, authorizer Authorizer, uri string, params interface{}, mediaType string, v interface{}, options ...RequestOption) (*Response, error) {
+	// copied so the callers slice is never written to, even if it has spare capacity
+	options = append(append([]RequestOption(nil), options...), WithAccept(mediaType))
+	return c.GetContext(ctx, authorizer, uri, params, v, options...)
+}
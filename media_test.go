@@ -0,0 +1,67 @@
+package crusch
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestGetMedia(t *testing.T) {
+	rt := &fakeTransport{handler: func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.Header.Get("Accept")))
+	}}
+	client := setupFakeClient(rt)
+
+	var diff string
+	_, err := client.GetMedia(setupAuth(), "repos/weavc/crusch/pulls/1", nil, MediaTypeDiff, &diff)
+	if err != nil {
+		t.Errorf("diff: unexpected %v", err)
+	}
+
+	if accept := rt.Request().Header.Values("Accept"); diff != MediaTypeDiff || len(accept) != 1 {
+		t.Errorf("diff: returned %q with accept headers %v", diff, accept)
+	}
+
+	var raw []byte
+	_, err = client.GetMedia(setupAuth(), "repos/weavc/crusch/contents/readme.md", nil, MediaTypeRaw, &raw)
+	if err != nil || string(raw) != MediaTypeRaw {
+		t.Errorf("raw: returned %q, %v", raw, err)
+	}
+
+	var buf bytes.Buffer
+	_, err = client.GetMedia(setupAuth(), "repos/weavc/crusch/pulls/1", nil, MediaTypePatch, &buf)
+	if err != nil || buf.String() != MediaTypePatch {
+		t.Errorf("patch: returned %q, %v", buf.String(), err)
+	}
+
+	var s string
+	_, err = client.Get(setupAuth(), "repos/weavc/crusch", nil, &s)
+	if err != nil || s != "application/vnd.github.machine-man-preview+json" {
+		t.Errorf("default accept: returned %q, %v", s, err)
+	}
+}
+
+func TestGetMediaSharedOptions(t *testing.T) {
+	rt := &fakeTransport{handler: func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.Header.Get("Accept")))
+	}}
+	client := setupFakeClient(rt)
+
+	// spare capacity must not be written to, the slice may be shared between goroutines
+	base := make([]RequestOption, 1, 4)
+	base[0] = WithAPIVersion("2022-11-28")
+
+	var diff, patch string
+	_, err := client.GetMedia(setupAuth(), "test/uri", nil, MediaTypeDiff, &diff, base...)
+	if err != nil {
+		t.Fatalf("shared options: unexpected %v", err)
+	}
+	_, err = client.GetMedia(setupAuth(), "test/uri", nil, MediaTypePatch, &patch, base...)
+	if err != nil {
+		t.Fatalf("shared options: unexpected %v", err)
+	}
+
+	if diff != MediaTypeDiff || patch != MediaTypePatch || base[:2][1] != nil {
+		t.Errorf("shared options: returned %s %s, wrote to the callers options", diff, patch)
+	}
+}
@@ -0,0 +1,51 @@
+package crusch
+
+import "context"
+
+// Meta is the information Github provides about itself from the /meta endpoint
+// https://docs.github.com/en/rest/meta/meta#get-github-meta-information
+type Meta struct {
+	VerifiablePasswordAuthentication bool `json:"verifiable_password_authentication"`
+	// InstalledVersion is the version of Github Enterprise Server, empty for github.com
+	InstalledVersion string   `json:"installed_version"`
+	Hooks            []string `json:"hooks"`
+	Web              []string `json:"web"`
+	API              []string `json:"api"`
+	Git              []string `json:"git"`
+	Pages            []string `json:"pages"`
+	Importer         []string `json:"importer"`
+	Actions          []string `json:"actions"`
+	Dependabot       []string `json:"dependabot"`
+}
+
+// GetMeta requests the meta information of the clients Github instance
+func (c *Client) GetMeta(authorizer Authorizer, options ...RequestOption) (*Meta, *Response, error) {
+	return c.GetMetaContext(context.Background(), authorizer, options...)
+}
+
+// GetMetaContext is GetMeta using the given context
+func (c *Client) GetMetaContext(ctx context.Context, authorizer Authorizer, options ...RequestOption) (*Meta, *Response, error) {
+	v := &Meta{}
+	res, err := c.GetContext(ctx, authorizer, "meta", nil, v, options...)
+	if err != nil {
+		return nil, res, err
+	}
+
+	return v, res, nil
+}
+
+// EnterpriseVersion detects the version of Github Enterprise Server the client is using
+// The version is empty if the client is not using Github Enterprise Server i.e. github.com
+func (c *Client) EnterpriseVersion(authorizer Authorizer, options ...RequestOption) (string, error) {
+	return c.EnterpriseVersionContext(context.Background(), authorizer, options...)
+}
+
+// EnterpriseVersionContext is EnterpriseVersion using the given context
+func (c *Client) EnterpriseVersionContext(ctx context.Context, authorizer Authorizer, options ...RequestOption) (string, error) {
+	meta, _, err := c.GetMetaContext(ctx, authorizer, options...)
+	if err != nil {
+		return "", err
+	}
+
+	return meta.InstalledVersion, nil
+}
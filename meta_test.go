@@ -0,0 +1,92 @@
+package crusch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewEnterpriseClient(t *testing.T) {
+	cases := []struct {
+		baseURL string
+		url     string
+		web     string
+		upload  string
+		graphQL string
+	}{
+		{"https://github.example.com", "github.example.com/api/v3", "https://github.example.com", "https://github.example.com/api/uploads", "https://github.example.com/api/graphql"},
+		{"github.example.com/", "github.example.com/api/v3", "https://github.example.com", "https://github.example.com/api/uploads", "https://github.example.com/api/graphql"},
+		{"http://github.example.com/api/v3/", "github.example.com/api/v3", "http://github.example.com", "http://github.example.com/api/uploads", "http://github.example.com/api/graphql"},
+	}
+
+	for _, c := range cases {
+		client, err := NewEnterpriseClient(c.baseURL)
+		if err != nil {
+			t.Errorf("enterprise client %s: unexpected %v", c.baseURL, err)
+			continue
+		}
+
+		if client.URL != c.url {
+			t.Errorf("enterprise client %s: url %s want %s", c.baseURL, client.URL, c.url)
+		}
+		if client.WebURL() != c.web {
+			t.Errorf("enterprise client %s: web url %s want %s", c.baseURL, client.WebURL(), c.web)
+		}
+		if client.uploadURL() != c.upload {
+			t.Errorf("enterprise client %s: upload url %s want %s", c.baseURL, client.uploadURL(), c.upload)
+		}
+		if client.graphQLURL() != c.graphQL {
+			t.Errorf("enterprise client %s: graphql url %s want %s", c.baseURL, client.graphQLURL(), c.graphQL)
+		}
+	}
+
+	_, err := NewEnterpriseClient("https://")
+	if err == nil {
+		t.Errorf("enterprise client without host: unexpected nil error")
+	}
+
+	if web := GithubClient.WebURL(); web != "https://github.com" {
+		t.Errorf("github client: web url %s want %s", web, "https://github.com")
+	}
+}
+
+func TestEnterpriseInstallationAuth(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/api/v3/app/installations/2/access_tokens":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"token": "ghs_enterprise"}`))
+		case "/api/v3/meta":
+			if r.Header.Get("Authorization") != "token ghs_enterprise" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"verifiable_password_authentication": true, "installed_version": "3.10.2"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewEnterpriseClient(server.URL)
+	if err != nil {
+		t.Fatalf("enterprise client: unexpected %v", err)
+	}
+
+	auth, err := client.NewInstallationAuth(1, 2, getKey())
+	if err != nil || auth.Client != client {
+		t.Fatalf("enterprise installation auth: returned %v, %v", auth, err)
+	}
+
+	version, err := client.EnterpriseVersion(auth)
+	if err != nil || version != "3.10.2" {
+		t.Errorf("enterprise version: returned %s, %v want %s", version, err, "3.10.2")
+	}
+
+	want := []string{"/api/v3/app/installations/2/access_tokens", "/api/v3/meta"}
+	if len(paths) != len(want) || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("enterprise installation auth: requested %v want %v", paths, want)
+	}
+}
@@ -0,0 +1,227 @@
+package crusch
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Metrics records measurements of the requests made by a Client and the tokens requested by InstallationAuth
+// Implementations must be safe for concurrent use, adapters can be written for any metrics library
+// route is the path of the request before path parameters were expanded, see WithPathParams
+// Requests made without WithPathParams are recorded with the RouteUnknown route
+type Metrics interface {
+	// ObserveRequest records a completed request, status is 0 if no response was received
+	ObserveRequest(method string, route string, status int, duration time.Duration)
+	// IncRetry records a request being retried
+	IncRetry(method string, route string)
+	// ObserveCache records whether a cacheable request was served from the cache
+	ObserveCache(route string, hit bool)
+	// SetRateLimit records the rate limit headroom of an authorizer, identity is the authorizers Identity
+	// i.e. installation/123
+	SetRateLimit(identity string, resource string, remaining int, limit int)
+	// ObserveTokenRefresh records an installation access token request, failed is true if it did not succeed
+	ObserveTokenRefresh(identity string, failed bool)
+}
+
+// NopMetrics discards all measurements, it is used by clients without Metrics
+type NopMetrics struct{}
+
+// ObserveRequest to implement Metrics
+func (NopMetrics) ObserveRequest(method string, route string, status int, duration time.Duration) {}
+
+// IncRetry to implement Metrics
+func (NopMetrics) IncRetry(method string, route string) {}
+
+// ObserveCache to implement Metrics
+func (NopMetrics) ObserveCache(route string, hit bool) {}
+
+// SetRateLimit to implement Metrics
+func (NopMetrics) SetRateLimit(identity string, resource string, remaining int, limit int) {}
+
+// ObserveTokenRefresh to implement Metrics
+func (NopMetrics) ObserveTokenRefresh(identity string, failed bool) {}
+
+// SetMetrics sets the Metrics measurements are recorded to, nil (default) discards them
+func (c *Client) SetMetrics(metrics Metrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics = metrics
+}
+
+// WithMetrics returns a clone of the client recording measurements to metrics
+func (c *Client) WithMetrics(metrics Metrics) *Client {
+	clone := c.Clone()
+	clone.SetMetrics(metrics)
+	return clone
+}
+
+func (c *Client) getMetrics() Metrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.metrics == nil {
+		return NopMetrics{}
+	}
+	return c.metrics
+}
+
+// observeRequest logs and records metrics for a request once it has passed through the middleware chain
+func (c *Client) observeRequest(req *Request, res *Response, err error, duration time.Duration) {
+	status := 0
+	if res != nil && res.Response != nil {
+		status = res.StatusCode
+	}
+
+	c.getMetrics().ObserveRequest(req.Method, req.Route, status, duration)
+	c.logRequest(req, res, err, duration)
+}
+
+// MemoryMetrics keeps measurements in memory, intended for tests and debugging
+type MemoryMetrics struct {
+	mu            sync.Mutex
+	requests      map[string]*RequestStats
+	retries       map[string]int
+	cacheHits     map[string]int
+	cacheMisses   map[string]int
+	rates         map[string]RateStats
+	refreshes     map[string]int
+	refreshErrors map[string]int
+}
+
+// RequestStats are the measurements of requests with the same method, route and status
+type RequestStats struct {
+	Count int
+	Total time.Duration
+	Max   time.Duration
+}
+
+// RateStats is the last rate limit headroom recorded for an identity and resource
+type RateStats struct {
+	Remaining int
+	Limit     int
+}
+
+// NewMemoryMetrics creates an empty MemoryMetrics
+func NewMemoryMetrics() *MemoryMetrics {
+	return &MemoryMetrics{
+		requests:      map[string]*RequestStats{},
+		retries:       map[string]int{},
+		cacheHits:     map[string]int{},
+		cacheMisses:   map[string]int{},
+		rates:         map[string]RateStats{},
+		refreshes:     map[string]int{},
+		refreshErrors: map[string]int{},
+	}
+}
+
+// ObserveRequest to implement Metrics
+func (m *MemoryMetrics) ObserveRequest(method string, route string, status int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := requestKey(method, route, status)
+	s, ok := m.requests[key]
+	if !ok {
+		s = &RequestStats{}
+		m.requests[key] = s
+	}
+
+	s.Count++
+	s.Total += duration
+	if duration > s.Max {
+		s.Max = duration
+	}
+}
+
+// IncRetry to implement Metrics
+func (m *MemoryMetrics) IncRetry(method string, route string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retries[fmt.Sprintf("%s %s", method, route)]++
+}
+
+// ObserveCache to implement Metrics
+func (m *MemoryMetrics) ObserveCache(route string, hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if hit {
+		m.cacheHits[route]++
+	} else {
+		m.cacheMisses[route]++
+	}
+}
+
+// SetRateLimit to implement Metrics
+func (m *MemoryMetrics) SetRateLimit(identity string, resource string, remaining int, limit int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rates[fmt.Sprintf("%s %s", identity, resource)] = RateStats{Remaining: remaining, Limit: limit}
+}
+
+// ObserveTokenRefresh to implement Metrics
+func (m *MemoryMetrics) ObserveTokenRefresh(identity string, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if failed {
+		m.refreshErrors[identity]++
+	} else {
+		m.refreshes[identity]++
+	}
+}
+
+// Requests returns the stats of requests with the method, route and status
+func (m *MemoryMetrics) Requests(method string, route string, status int) RequestStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.requests[requestKey(method, route, status)]; ok {
+		return *s
+	}
+	return RequestStats{}
+}
+
+// RequestKeys returns the "METHOD route status" keys of every request recorded, sorted
+func (m *MemoryMetrics) RequestKeys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]string, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Retries returns the number of retries of requests with the method and route
+func (m *MemoryMetrics) Retries(method string, route string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.retries[fmt.Sprintf("%s %s", method, route)]
+}
+
+// Cache returns the number of cache hits and misses for the route
+func (m *MemoryMetrics) Cache(route string) (hits int, misses int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cacheHits[route], m.cacheMisses[route]
+}
+
+// RateLimit returns the last rate limit headroom recorded for the identity and resource
+func (m *MemoryMetrics) RateLimit(identity string, resource string) (RateStats, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.rates[fmt.Sprintf("%s %s", identity, resource)]
+	return r, ok
+}
+
+// TokenRefreshes returns the number of successful and failed token requests for the identity
+func (m *MemoryMetrics) TokenRefreshes(identity string) (refreshes int, failures int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.refreshes[identity], m.refreshErrors[identity]
+}
+
+func requestKey(method string, route string, status int) string {
+	return fmt.Sprintf("%s %s %d", method, route, status)
+}
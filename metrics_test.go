@@ -0,0 +1,107 @@
+package crusch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	var flaky int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/app/installations/2/access_tokens":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"token": "ghs_token"}`))
+		case r.URL.Path == "/app/installations/3/access_tokens":
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message": "Bad credentials"}`))
+		case strings.HasPrefix(r.URL.Path, "/repos/"):
+			w.Header().Set("X-RateLimit-Limit", "5000")
+			w.Header().Set("X-RateLimit-Remaining", "4321")
+			w.Header().Set("X-RateLimit-Resource", "core")
+			w.Header().Set("ETag", `"crusch"`)
+			if r.Header.Get("If-None-Match") == `"crusch"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Write([]byte(`{}`))
+		case r.URL.Path == "/flaky":
+			if atomic.AddInt32(&flaky, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	metrics := NewMemoryMetrics()
+	client := NewGithubClient(strings.TrimPrefix(server.URL, "http://"), "http")
+	client.SetMetrics(metrics)
+	client.SetCache(NewMemoryCache(10))
+	client.SetRetryPolicy(&RetryPolicy{MaxAttempts: 2, Statuses: []int{503}, Methods: []string{http.MethodGet}})
+
+	auth, _ := client.NewInstallationAuth(1, 2, getKey())
+	params := WithPathParams(map[string]string{"owner": "weavc", "repo": "crusch"})
+	for i := 0; i < 2; i++ {
+		_, err := client.Get(auth, "repos/{owner}/{repo}", nil, nil, params)
+		if err != nil {
+			t.Fatalf("metrics: unexpected %v", err)
+		}
+	}
+
+	_, err := client.Get(setupAuth(), "flaky", nil, nil)
+	if err != nil {
+		t.Fatalf("metrics flaky: unexpected %v", err)
+	}
+
+	failing, _ := client.NewInstallationAuth(1, 3, getKey())
+	_, err = client.Get(failing, "repos/{owner}/{repo}", nil, nil, params)
+	if err == nil {
+		t.Fatalf("metrics failing token: unexpected nil error")
+	}
+
+	if s := metrics.Requests(http.MethodGet, "/repos/{owner}/{repo}", 200); s.Count != 2 || s.Total <= 0 || s.Max <= 0 || s.Max > s.Total {
+		t.Errorf("metrics: repo requests %+v", s)
+	}
+	if s := metrics.Requests(http.MethodPost, "/app/installations/{installation_id}/access_tokens", 201); s.Count != 1 {
+		t.Errorf("metrics: token requests %+v, recorded %v", s, metrics.RequestKeys())
+	}
+	if s := metrics.Requests(http.MethodGet, RouteUnknown, 200); s.Count != 1 {
+		t.Errorf("metrics: flaky requests %+v", s)
+	}
+
+	if n := metrics.Retries(http.MethodGet, RouteUnknown); n != 1 {
+		t.Errorf("metrics: retries %d want 1", n)
+	}
+
+	if hits, misses := metrics.Cache("/repos/{owner}/{repo}"); hits != 1 || misses != 1 {
+		t.Errorf("metrics: cache hits %d misses %d want 1 1", hits, misses)
+	}
+
+	rate, ok := metrics.RateLimit("installation/2", ResourceCore)
+	if !ok || rate.Remaining != 4321 || rate.Limit != 5000 {
+		t.Errorf("metrics: rate limit %+v, %v", rate, ok)
+	}
+
+	if refreshes, failures := metrics.TokenRefreshes("installation/2"); refreshes != 1 || failures != 0 {
+		t.Errorf("metrics: installation/2 refreshes %d failures %d want 1 0", refreshes, failures)
+	}
+	if refreshes, failures := metrics.TokenRefreshes("installation/3"); refreshes != 0 || failures != 1 {
+		t.Errorf("metrics: installation/3 refreshes %d failures %d want 0 1", refreshes, failures)
+	}
+}
+
+func TestNopMetrics(t *testing.T) {
+	var m Metrics = NopMetrics{}
+	m.ObserveRequest(http.MethodGet, "/", 200, time.Second)
+
+	client := setupClient(generalResponse)
+	if _, ok := client.getMetrics().(NopMetrics); !ok {
+		t.Errorf("nop metrics: client without metrics returned %T", client.getMetrics())
+	}
+}
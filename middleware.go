@@ -0,0 +1,88 @@
+package crusch
+
+import (
+	"context"
+	"net/http"
+)
+
+// Request is a request made by a Client, as seen by Middleware
+type Request struct {
+	*http.Request
+
+	// Authorizer provides the Authorization header of the request, it is added after the middleware chain
+	Authorizer Authorizer
+	// Route is the path of the request before any path parameters were expanded
+	// i.e. /repos/{owner}/{repo} when WithPathParams is used, or RouteUnknown otherwise
+	Route string
+	// Target is the value the response body will be bound to, nil if the body is not bound
+	// It has been bound once the next Doer returns successfully
+	Target interface{}
+}
+
+// Doer performs requests, returning the response
+type Doer interface {
+	Do(req *Request) (*Response, error)
+}
+
+// DoerFunc is a function implementing Doer
+type DoerFunc func(req *Request) (*Response, error)
+
+// Do to implement Doer
+func (f DoerFunc) Do(req *Request) (*Response, error) {
+	return f(req)
+}
+
+// Middleware wraps the Doer performing a request
+// It can modify the request before passing it to next, inspect the response next returns,
+// or return a response or error without calling next at all i.e. for policy checks or fault injection
+// Responses returned without calling next are not bound to the requests Target
+type Middleware func(next Doer) Doer
+
+// Use adds middleware to the client, each request passes through them in the order they were added
+func (c *Client) Use(middleware ...Middleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// copied so clients sharing the previous slice are not modified
+	m := make([]Middleware, 0, len(c.middleware)+len(middleware))
+	c.middleware = append(append(m, c.middleware...), middleware...)
+}
+
+// WithMiddleware returns a clone of the client with the given middleware added
+func (c *Client) WithMiddleware(middleware ...Middleware) *Client {
+	clone := c.Clone()
+	clone.Use(middleware...)
+	return clone
+}
+
+// chain wraps final in the clients middleware, the first middleware added is the outermost
+func (c *Client) chain(final Doer) Doer {
+	c.mu.Lock()
+	middleware := c.middleware
+	c.mu.Unlock()
+
+	doer := final
+	for i := len(middleware) - 1; i >= 0; i-- {
+		doer = middleware[i](doer)
+	}
+	return doer
+}
+
+// RouteUnknown is the route of requests made without WithPathParams
+// Their paths are not used as routes, so metrics, logs and spans keep a bounded set of routes
+const RouteUnknown = "unknown"
+
+type routeKey struct{}
+
+// withRoute records route as the route of req, for the layers below the middleware chain
+func withRoute(req *http.Request, route string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), routeKey{}, route))
+}
+
+// requestRoute returns the route recorded for req, or RouteUnknown if none was recorded
+func requestRoute(req *http.Request) string {
+	if route, ok := req.Context().Value(routeKey{}).(string); ok {
+		return route
+	}
+	return RouteUnknown
+}
@@ -0,0 +1,114 @@
+package crusch
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	client := setupClient(generalResponse)
+	auth := setupAuth()
+
+	var calls []string
+	var route string
+	var target interface{}
+	record := func(name string) Middleware {
+		return func(next Doer) Doer {
+			return DoerFunc(func(req *Request) (*Response, error) {
+				calls = append(calls, name)
+				if req.Authorizer == nil || req.Header.Get("Authorization") != "" {
+					t.Errorf("middleware %s: authorizer %v, authorization header %s", name, req.Authorizer, req.Header.Get("Authorization"))
+				}
+				route, target = req.Route, req.Target
+				req.Header.Set("X-"+name, "1")
+				return next.Do(req)
+			})
+		}
+	}
+	client.Use(record("First"), record("Second"))
+
+	var v m
+	res, err := client.Get(auth, "repos/{owner}/{repo}", nil, &v,
+		WithPathParams(map[string]string{"owner": "weavc", "repo": "crusch"}))
+	if err != nil {
+		t.Fatalf("middleware: unexpected %v", err)
+	}
+
+	if !reflect.DeepEqual(calls, []string{"First", "Second"}) {
+		t.Errorf("middleware: called %v want %v", calls, []string{"First", "Second"})
+	}
+	if route != "/repos/{owner}/{repo}" || target != &v {
+		t.Errorf("middleware: saw route %s target %v", route, target)
+	}
+	if res.Request.Header.Get("X-First") != "1" || res.Request.Header.Get("X-Second") != "1" {
+		t.Errorf("middleware: sent headers %v", res.Request.Header)
+	}
+	if v.Weavc != "crusch" {
+		t.Errorf("middleware: bound %v", v)
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	rt := &fakeTransport{body: generalResponse}
+	client := setupFakeClient(rt)
+
+	injected := errors.New("injected fault")
+	faulty := client.WithMiddleware(func(next Doer) Doer {
+		return DoerFunc(func(req *Request) (*Response, error) {
+			if req.Method == http.MethodDelete {
+				return nil, injected
+			}
+			return next.Do(req)
+		})
+	})
+
+	_, err := faulty.Delete(setupAuth(), "repos/weavc/crusch")
+	if err != injected || rt.Calls() != 0 {
+		t.Errorf("short circuit: returned %v after %d requests want %v", err, rt.Calls(), injected)
+	}
+
+	_, err = faulty.Get(setupAuth(), "repos/weavc/crusch", nil, nil)
+	if err != nil || rt.Calls() != 1 {
+		t.Errorf("short circuit, passed: returned %v after %d requests", err, rt.Calls())
+	}
+
+	_, err = client.Delete(setupAuth(), "repos/weavc/crusch")
+	if err != nil {
+		t.Errorf("short circuit, original client: unexpected %v", err)
+	}
+}
+
+func TestMiddlewareDownload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("crusch"))
+	}))
+	defer server.Close()
+
+	var status int
+	client := NewGithubClient(strings.TrimPrefix(server.URL, "http://"), "http")
+	client.Use(func(next Doer) Doer {
+		return DoerFunc(func(req *Request) (*Response, error) {
+			res, err := next.Do(req)
+			if res != nil {
+				status = res.StatusCode
+			}
+			return res, err
+		})
+	})
+
+	body, _, err := client.Download(setupAuth(), "repos/weavc/crusch/tarball", nil)
+	if err != nil {
+		t.Fatalf("middleware download: unexpected %v", err)
+	}
+	defer body.Close()
+
+	b, _ := ioutil.ReadAll(body)
+	if string(b) != "crusch" || status != http.StatusOK {
+		t.Errorf("middleware download: read %s, saw status %d", b, status)
+	}
+}
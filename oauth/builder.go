@@ -5,6 +5,7 @@ import "net/http"
 type Builder interface {
 	Build() *OauthService
 	ConfigureGithub(secret string, clientId string, redirectUri string) Builder
+	ConfigureBaseURL(baseURL string) Builder
 	Configure(func(*ClientConfig)) Builder
 	RegisterCustomResponseHandler(http.HandlerFunc) Builder
 	RegisterCustomStateHandler(func(r *http.Request) string) Builder
@@ -35,6 +36,18 @@ func (b *builder) ConfigureGithub(secret string, clientId string, redirectUri st
 	return b
 }
 
+// ConfigureBaseURL sets the web url of Github, used for Github Enterprise Server
+// i.e. https://github.example.com or crusch.Client.WebURL()
+// ConfigureGithub must be called first
+func (b *builder) ConfigureBaseURL(baseURL string) Builder {
+	if b.oauthService.githubConfig == nil {
+		panic("Please configure your github credentials by calling ConfigureGithub(privateKey string, clientId string) before configuring the base url.")
+	}
+
+	b.oauthService.githubConfig.BaseURL = baseURL
+	return b
+}
+
 func (b *builder) Configure(configureFunc func(*ClientConfig)) Builder {
 	configureFunc(b.oauthService.clientConfig)
 	return b
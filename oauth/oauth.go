@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/weavc/crusch/internal"
@@ -32,7 +33,7 @@ func (s *OauthService) AccessCode(code string, state string) (*AccessToken, erro
 	}
 
 	client := http.DefaultClient
-	req, err := http.NewRequest(http.MethodPost, "https://github.com/login/oauth/access_token", bytes.NewBuffer([]byte(body)))
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/login/oauth/access_token", s.baseURL()), bytes.NewBuffer([]byte(body)))
 	if err != nil {
 		panic(err)
 	}
@@ -72,7 +73,15 @@ func (s *OauthService) Redirect(state string) string {
 		panic(err)
 	}
 
-	return fmt.Sprintf("https://github.com/login/oauth/authorize?%s", qs)
+	return fmt.Sprintf("%s/login/oauth/authorize?%s", s.baseURL(), qs)
+}
+
+// baseURL returns the web url of Github, defaulting to https://github.com
+func (s *OauthService) baseURL() string {
+	if s.githubConfig.BaseURL == "" {
+		return "https://github.com"
+	}
+	return strings.TrimRight(s.githubConfig.BaseURL, "/")
 }
 
 func (s *OauthService) OauthRedirectHandler(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,128 @@
+package oauth
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedirect(t *testing.T) {
+	cases := []struct {
+		name    string
+		baseURL string
+		want    string
+	}{
+		{"github", "", "https://github.com/login/oauth/authorize"},
+		{"enterprise", "https://github.example.com/", "https://github.example.com/login/oauth/authorize"},
+	}
+
+	for _, c := range cases {
+		b := NewBuilder().ConfigureGithub("secret", "clientid", "https://crusch.example.com/callback")
+		if c.baseURL != "" {
+			b = b.ConfigureBaseURL(c.baseURL)
+		}
+		s := b.Build()
+
+		w := httptest.NewRecorder()
+		s.OauthRedirectHandler(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+
+		u, err := url.Parse(w.Header().Get("Location"))
+		if err != nil || w.Code != http.StatusFound {
+			t.Fatalf("%s redirect: returned %d %s, %v", c.name, w.Code, w.Header().Get("Location"), err)
+		}
+
+		q := u.Query()
+		if got := u.Scheme + "://" + u.Host + u.Path; got != c.want {
+			t.Errorf("%s redirect: redirected to %s want %s", c.name, got, c.want)
+		}
+		if q.Get("client_id") != "clientid" || q.Get("state") != "12345" || q.Get("redirect_uri") != "https://crusch.example.com/callback" {
+			t.Errorf("%s redirect: query %v", c.name, q)
+		}
+	}
+}
+
+func TestAccessCode(t *testing.T) {
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		form, _ = url.ParseQuery(string(b))
+
+		if r.Method != http.MethodPost || r.URL.Path != "/login/oauth/access_token" || r.Header.Get("Accept") != "application/json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if form.Get("code") == "bad" {
+			w.Write([]byte(`{"error": "bad_verification_code", "error_description": "The code passed is incorrect or expired."}`))
+			return
+		}
+		w.Write([]byte(`{"access_token": "gho_token", "token_type": "bearer", "scope": "repo"}`))
+	}))
+	defer server.Close()
+
+	// requests to github.com are sent to the test server instead
+	var requested *url.URL
+	defaultClient := http.DefaultClient
+	defer func() { http.DefaultClient = defaultClient }()
+	http.DefaultClient = &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		original := *r.URL
+		requested = &original
+		u, _ := url.Parse(server.URL)
+		r.URL.Scheme, r.URL.Host = u.Scheme, u.Host
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+
+	cases := []struct {
+		name    string
+		baseURL string
+		want    string
+	}{
+		{"github", "", "https://github.com/login/oauth/access_token"},
+		{"enterprise", server.URL + "/", server.URL + "/login/oauth/access_token"},
+	}
+
+	for _, c := range cases {
+		b := NewBuilder().ConfigureGithub("secret", "clientid", "https://crusch.example.com/callback")
+		if c.baseURL != "" {
+			b = b.ConfigureBaseURL(c.baseURL)
+		}
+		s := b.Build()
+
+		token, err := s.AccessCode("code", "12345")
+		if err != nil || token.AccessToken != "gho_token" || token.Scope != "repo" {
+			t.Fatalf("%s access code: returned %+v, %v", c.name, token, err)
+		}
+
+		if requested == nil || requested.String() != c.want {
+			t.Errorf("%s access code: requested %v want %s", c.name, requested, c.want)
+		}
+
+		if form.Get("client_id") != "clientid" || form.Get("client_secret") != "secret" || form.Get("code") != "code" || form.Get("state") != "12345" {
+			t.Errorf("%s access code: sent %v", c.name, form)
+		}
+
+		_, err = s.AccessCode("bad", "12345")
+		if err == nil || !strings.Contains(err.Error(), "bad_verification_code") {
+			t.Errorf("%s access code, bad code: returned %v", c.name, err)
+		}
+	}
+}
+
+func TestConfigureBaseURLWithoutGithub(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("configure base url: did not panic without github credentials")
+		}
+	}()
+
+	NewBuilder().ConfigureBaseURL("https://github.example.com")
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -6,6 +6,9 @@ type GithubConfig struct {
 	Secret      string
 	ClientId    string
 	RedirectUri string
+	// BaseURL is the web url of Github, https://github.com if empty
+	// Set this to the url of the instance when using Github Enterprise Server
+	BaseURL string
 }
 
 type ClientConfig struct {
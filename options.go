@@ -0,0 +1,161 @@
+package crusch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/weavc/crusch/internal"
+)
+
+// RequestOption customises a single request made by a client
+// Options only affect the request they are passed to, the client itself is never modified
+type RequestOption func(*requestOptions)
+
+type requestOptions struct {
+	header     http.Header
+	query      []interface{}
+	timeout    time.Duration
+	stream     bool
+	pathParams map[string]string
+	route      string
+}
+
+// WithHeader sets a header on the request, replacing any value set by the client
+func WithHeader(name string, value string) RequestOption {
+	return func(o *requestOptions) {
+		o.header.Set(name, value)
+	}
+}
+
+// WithAccept sets the Accept header of the request, see the MediaType constants
+func WithAccept(mediaType string) RequestOption {
+	return WithHeader("Accept", mediaType)
+}
+
+// WithAPIVersion sets the X-GitHub-Api-Version header, selecting a calendar version of the REST API
+// https://docs.github.com/en/rest/overview/api-versions
+func WithAPIVersion(version string) RequestOption {
+	return WithHeader("X-GitHub-Api-Version", version)
+}
+
+// WithQuery adds parameters to the querystring of the request
+// params can be a string, struct, url.Values or nil as with Get, it can be used with any method
+func WithQuery(params interface{}) RequestOption {
+	return func(o *requestOptions) {
+		o.query = append(o.query, params)
+	}
+}
+
+// WithTimeout limits how long the request can take, including reading the response body
+func WithTimeout(timeout time.Duration) RequestOption {
+	return func(o *requestOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithStream keeps the response body open so it can be read by the caller
+// Without this the body is drained and closed once the request completes
+// The caller must close the body of streamed responses
+func WithStream() RequestOption {
+	return func(o *requestOptions) {
+		o.stream = true
+	}
+}
+
+// withRequestRoute sets the route of the request, used when its path has already been expanded
+// i.e. the next links of a paginator using WithPathParams
+func withRequestRoute(route string) RequestOption {
+	return func(o *requestOptions) {
+		o.route = route
+	}
+}
+
+func newRequestOptions(options []RequestOption) *requestOptions {
+	o := &requestOptions{header: http.Header{}}
+	for _, option := range options {
+		option(o)
+	}
+	return o
+}
+
+// apply applies the options to req, returning the request to use
+// cancel must be called once the request and response body are finished with
+func (o *requestOptions) apply(req *http.Request) (*http.Request, context.CancelFunc, error) {
+	cancel := context.CancelFunc(func() {})
+
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	for name, values := range o.header {
+		req.Header[name] = values
+	}
+
+	if o.pathParams != nil {
+		err := expandRequestPath(req, o.pathParams)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, params := range o.query {
+		query, err := internal.ParseQuery(params)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if query == "" {
+			continue
+		}
+		if req.URL.RawQuery == "" {
+			req.URL.RawQuery = query
+		} else {
+			req.URL.RawQuery = fmt.Sprintf("%s&%s", req.URL.RawQuery, strings.TrimLeft(query, "?&"))
+		}
+	}
+
+	if o.timeout > 0 {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(req.Context(), o.timeout)
+		req = req.WithContext(ctx)
+	}
+
+	return req, cancel, nil
+}
+
+// cancelOnClose cancels the requests context once the response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// releaseOnClose calls cancel once res is finished with
+func releaseOnClose(res *http.Response, cancel context.CancelFunc) {
+	if res == nil || res.Body == nil {
+		cancel()
+		return
+	}
+	res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+}
+
+// closeBody drains and closes the response body so the connection can be reused
+// the body is replaced with http.NoBody so later reads return io.EOF
+func closeBody(res *http.Response) {
+	if res == nil || res.Body == nil {
+		return
+	}
+
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	res.Body = http.NoBody
+}
@@ -0,0 +1,75 @@
+package crusch
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRequestOptions(t *testing.T) {
+	rt := &fakeTransport{body: "{}"}
+	client := setupFakeClient(rt)
+	headers := client.Headers()
+
+	_, err := client.Post(setupAuth(), "repos/weavc/crusch/issues", nil, nil,
+		WithHeader("X-Custom", "crusch"),
+		WithAPIVersion("2022-11-28"),
+		WithAccept(MediaTypeJSON),
+		WithQuery("a=1"),
+		WithQuery(url.Values{"b": {"2"}}),
+	)
+	if err != nil {
+		t.Fatalf("request options: unexpected %v", err)
+	}
+
+	req := rt.Request()
+	if req.Header.Get("X-Custom") != "crusch" || req.Header.Get("X-GitHub-Api-Version") != "2022-11-28" {
+		t.Errorf("request options: sent headers %v", req.Header)
+	}
+
+	if accept := req.Header.Values("Accept"); !reflect.DeepEqual(accept, []string{MediaTypeJSON}) {
+		t.Errorf("request options: sent accept %v want %v", accept, []string{MediaTypeJSON})
+	}
+
+	if req.URL.RawQuery != "a=1&b=2" {
+		t.Errorf("request options: sent query %s want %s", req.URL.RawQuery, "a=1&b=2")
+	}
+
+	if h := client.Headers(); !reflect.DeepEqual(h, headers) {
+		t.Errorf("request options: client headers were modified %v", h)
+	}
+
+	_, err = client.Get(setupAuth(), "repos/weavc/crusch/issues", "state=open", nil, WithQuery("per_page=10"))
+	if err != nil || rt.Request().URL.RawQuery != "state=open&per_page=10" {
+		t.Errorf("request options get: sent query %s, %v", rt.Request().URL.RawQuery, err)
+	}
+
+	_, err = client.Get(setupAuth(), "test/uri", nil, nil, WithQuery(123))
+	if err == nil {
+		t.Errorf("request options bad query: unexpected nil error")
+	}
+}
+
+func TestRequestOptionTimeout(t *testing.T) {
+	rt := &fakeTransport{body: "{}", delay: time.Second}
+	client := setupFakeClient(rt)
+
+	start := time.Now()
+	_, err := client.Get(setupAuth(), "test/uri", nil, nil, WithTimeout(20*time.Millisecond))
+	if err == nil || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("timeout: returned %v after %v", err, time.Since(start))
+	}
+
+	rt.delay = 0
+	res, err := client.Get(setupAuth(), "test/uri", nil, nil, WithTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("timeout not reached: unexpected %v", err)
+	}
+
+	res.Body.Close()
+	if rt.Request().Context().Err() != context.Canceled {
+		t.Errorf("timeout: context was not released when the body was closed")
+	}
+}
@@ -0,0 +1,235 @@
+package crusch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/weavc/crusch/internal"
+)
+
+// Links are the pagination links Github provides in the Link header of list responses
+// Each link is a full url, or empty if Github did not provide it
+// https://docs.github.com/en/rest/guides/using-pagination-in-the-rest-api
+type Links struct {
+	First string
+	Prev  string
+	Next  string
+	Last  string
+}
+
+// ParseLinks parses the Link header from the given headers into Links
+func ParseLinks(header http.Header) Links {
+	var l Links
+
+	for _, v := range header.Values("Link") {
+		for _, link := range strings.Split(v, ",") {
+			segments := strings.Split(strings.TrimSpace(link), ";")
+			if len(segments) < 2 {
+				continue
+			}
+
+			u := strings.TrimSpace(segments[0])
+			if !strings.HasPrefix(u, "<") || !strings.HasSuffix(u, ">") {
+				continue
+			}
+			u = u[1 : len(u)-1]
+
+			for _, segment := range segments[1:] {
+				segment = strings.TrimSpace(segment)
+				if !strings.HasPrefix(segment, "rel=") {
+					continue
+				}
+
+				switch strings.Trim(segment[len("rel="):], "\"") {
+				case "first":
+					l.First = u
+				case "prev":
+					l.Prev = u
+				case "next":
+					l.Next = u
+				case "last":
+					l.Last = u
+				}
+			}
+		}
+	}
+
+	return l
+}
+
+// Paginator follows the next links of a paginated list endpoint
+// Pages are requested lazily using Client.Get, one per call to Next
+type Paginator struct {
+	// PerPage sets the per_page parameter on the first request, ignored if <= 0
+	PerPage int
+	// Key unwraps envelope responses i.e. {"total_count": 1, "repositories": [...]}
+	// when set, the value under Key is bound instead of the whole response
+	Key string
+	// TotalCount is the total_count of the last envelope response, if one was provided
+	TotalCount int
+	// Links are the links from the last page retrieved
+	Links Links
+
+	client     *Client
+	authorizer Authorizer
+	uri        string
+	params     interface{}
+	options    []RequestOption
+	route      string
+	started    bool
+}
+
+// NewPaginator creates a Paginator for the given uri using the clients Get method
+// params are used for the first request, Githubs next links already contain them after that
+// options are used for every request
+func (c *Client) NewPaginator(authorizer Authorizer, uri string, params interface{}, options ...RequestOption) *Paginator {
+	return &Paginator{
+		client:     c,
+		authorizer: authorizer,
+		uri:        uri,
+		params:     params,
+		options:    options,
+	}
+}
+
+// HasNext reports whether there is another page to retrieve
+func (p *Paginator) HasNext() bool {
+	return !p.started || p.Links.Next != ""
+}
+
+// Next retrieves the next page and binds it to v
+func (p *Paginator) Next(v interface{}) (*Response, error) {
+	return p.NextContext(context.Background(), v)
+}
+
+// NextContext retrieves the next page using the given context and binds it to v
+func (p *Paginator) NextContext(ctx context.Context, v interface{}) (*Response, error) {
+	if !p.HasNext() {
+		return nil, fmt.Errorf("no more pages to retrieve")
+	}
+
+	var params interface{}
+	uri := p.Links.Next
+	if !p.started {
+		query, err := p.firstQuery()
+		if err != nil {
+			return nil, err
+		}
+		uri, params = p.uri, query
+	} else if isAbsolute(uri) {
+		err := p.client.checkHost(uri)
+		if err != nil {
+			return nil, fmt.Errorf("invalid next link: %v", err)
+		}
+	}
+
+	options := p.options
+	if p.started {
+		// next links are already expanded, so the route of the first page is kept
+		options = append(append([]RequestOption(nil), p.options...), withRequestRoute(p.route))
+	}
+
+	var raw json.RawMessage
+	res, err := p.client.GetContext(ctx, p.authorizer, uri, params, &raw, options...)
+	if err != nil {
+		return res, err
+	}
+
+	if !p.started {
+		p.route = RouteUnknown
+		if res.Request != nil {
+			p.route = requestRoute(res.Request)
+		}
+	}
+	p.started = true
+	p.Links = res.Links
+
+	return res, p.bind(raw, v)
+}
+
+// All retrieves every remaining page, appending the results of each to v
+// v must be a pointer to a slice
+func (p *Paginator) All(v interface{}) error {
+	return p.AllContext(context.Background(), v)
+}
+
+// AllContext retrieves every remaining page using the given context, appending the results of each to v
+// v must be a pointer to a slice
+func (p *Paginator) AllContext(ctx context.Context, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("v must be a non nil pointer to a slice")
+	}
+
+	slice := rv.Elem()
+	for p.HasNext() {
+		page := reflect.New(slice.Type())
+		_, err := p.NextContext(ctx, page.Interface())
+		if err != nil {
+			return err
+		}
+
+		slice.Set(reflect.AppendSlice(slice, page.Elem()))
+	}
+
+	return nil
+}
+
+// firstQuery builds the querystring for the first request, adding per_page if required
+func (p *Paginator) firstQuery() (string, error) {
+	query, err := internal.ParseQuery(p.params)
+	if err != nil {
+		return "", err
+	}
+
+	if p.PerPage <= 0 {
+		return query, nil
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse query: %v", err)
+	}
+	values.Set("per_page", strconv.Itoa(p.PerPage))
+
+	return values.Encode(), nil
+}
+
+// bind decodes the raw page into v, unwrapping it from its envelope if Key is set
+func (p *Paginator) bind(raw json.RawMessage, v interface{}) error {
+	if p.Key == "" {
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(raw, v)
+	}
+
+	var envelope map[string]json.RawMessage
+	err := json.Unmarshal(raw, &envelope)
+	if err != nil {
+		return err
+	}
+
+	if total, ok := envelope["total_count"]; ok {
+		err = json.Unmarshal(total, &p.TotalCount)
+		if err != nil {
+			return err
+		}
+	}
+
+	items, ok := envelope[p.Key]
+	if !ok {
+		return fmt.Errorf("response does not contain key %s", p.Key)
+	}
+
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(items, v)
+}
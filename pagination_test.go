@@ -0,0 +1,230 @@
+package crusch
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseLinks(t *testing.T) {
+	h := http.Header{}
+	h.Set("Link", `<https://api.github.com/x?page=3>; rel="next", <https://api.github.com/x?page=1>; rel="prev",`+
+		` <https://api.github.com/x?page=1>; rel="first", <https://api.github.com/x?page=5>; rel="last"`)
+
+	want := Links{
+		First: "https://api.github.com/x?page=1",
+		Prev:  "https://api.github.com/x?page=1",
+		Next:  "https://api.github.com/x?page=3",
+		Last:  "https://api.github.com/x?page=5",
+	}
+
+	l := ParseLinks(h)
+	if !reflect.DeepEqual(l, want) {
+		t.Errorf("valid links: returned %v want %v", l, want)
+	}
+
+	l = ParseLinks(http.Header{})
+	if !reflect.DeepEqual(l, Links{}) {
+		t.Errorf("no links: returned %v want %v", l, Links{})
+	}
+}
+
+func TestPaginatorAll(t *testing.T) {
+	client := setupPageClient(3, "")
+
+	p := client.NewPaginator(setupAuth(), "test/uri", "state=open")
+	p.PerPage = 2
+
+	var v []int
+	err := p.All(&v)
+	if err != nil {
+		t.Errorf("valid all: unexpected %v", err)
+	}
+
+	want := []int{1, 1, 2, 2, 3, 3}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("valid all: returned %v want %v", v, want)
+	}
+
+	if p.HasNext() {
+		t.Errorf("valid all: paginator has next page after all")
+	}
+
+	var bad int
+	err = client.NewPaginator(setupAuth(), "test/uri", nil).All(&bad)
+	if err == nil {
+		t.Errorf("invalid all, not a slice: unexpected nil error")
+	}
+}
+
+func TestPaginatorEnvelope(t *testing.T) {
+	client := setupPageClient(2, "repositories")
+
+	p := client.NewPaginator(setupAuth(), "installation/repositories", nil)
+	p.Key = "repositories"
+
+	var v []int
+	err := p.All(&v)
+	if err != nil {
+		t.Errorf("valid envelope: unexpected %v", err)
+	}
+
+	if !reflect.DeepEqual(v, []int{1, 1, 2, 2}) {
+		t.Errorf("valid envelope: returned %v want %v", v, []int{1, 1, 2, 2})
+	}
+
+	if p.TotalCount != 4 {
+		t.Errorf("valid envelope: total count %d want %d", p.TotalCount, 4)
+	}
+
+	p = client.NewPaginator(setupAuth(), "installation/repositories", nil)
+	p.Key = "missing"
+	_, err = p.Next(&v)
+	if err == nil {
+		t.Errorf("invalid envelope key: unexpected nil error")
+	}
+}
+
+func TestPaginatorNext(t *testing.T) {
+	client := setupPageClient(5, "")
+
+	p := client.NewPaginator(setupAuth(), "test/uri", nil)
+
+	pages := 0
+	for p.HasNext() {
+		var v []int
+		_, err := p.Next(&v)
+		if err != nil {
+			t.Errorf("valid next: unexpected %v", err)
+			break
+		}
+
+		pages++
+		if pages == 2 {
+			break
+		}
+	}
+
+	if pages != 2 {
+		t.Errorf("valid next: retrieved %d pages want %d", pages, 2)
+	}
+
+	if p.Links.Next == "" || p.Links.Last == "" || p.Links.Prev == "" || p.Links.First == "" {
+		t.Errorf("valid next: missing links %v", p.Links)
+	}
+}
+
+func TestPaginatorForeignHost(t *testing.T) {
+	var foreign int32
+	stray := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&foreign, 1)
+		w.Write([]byte("[]"))
+	}))
+	defer stray.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", fmt.Sprintf(`<%s/test/uri?page=2>; rel="next"`, stray.URL))
+		w.Write([]byte("[1]"))
+	}))
+	defer api.Close()
+
+	client := NewGithubClient(strings.TrimPrefix(api.URL, "http://"), "http")
+	p := client.NewPaginator(setupAuth(), "test/uri", nil)
+
+	var v []int
+	err := p.All(&v)
+	if err == nil {
+		t.Errorf("foreign next link: returned nil want error")
+	}
+	if n := atomic.LoadInt32(&foreign); n != 0 {
+		t.Errorf("foreign next link: sent %d requests to foreign host want 0", n)
+	}
+	if !reflect.DeepEqual(v, []int{1}) {
+		t.Errorf("foreign next link: returned %v want %v", v, []int{1})
+	}
+}
+
+func TestPaginatorRoute(t *testing.T) {
+	client := setupPageClient(3, "")
+	metrics := NewMemoryMetrics()
+	client.SetMetrics(metrics)
+
+	var v []int
+	err := client.NewPaginator(setupAuth(), "repos/{owner}/{repo}/issues", nil,
+		WithPathParams(map[string]string{"owner": "weavc", "repo": "crusch"})).All(&v)
+	if err != nil {
+		t.Fatalf("route: unexpected %v", err)
+	}
+
+	want := []string{"GET /repos/{owner}/{repo}/issues 200"}
+	if keys := metrics.RequestKeys(); !reflect.DeepEqual(keys, want) {
+		t.Errorf("route: recorded %v want %v", keys, want)
+	}
+	if s := metrics.Requests(http.MethodGet, "/repos/{owner}/{repo}/issues", 200); s.Count != 3 {
+		t.Errorf("route: recorded %d requests want 3", s.Count)
+	}
+}
+
+func TestPaginatorRelativeLinks(t *testing.T) {
+	rt := &fakeTransport{}
+	rt.handler = func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", `<test/uri?page=2>; rel="next"`)
+			w.Write([]byte("[1]"))
+			return
+		}
+		w.Write([]byte("[2]"))
+	}
+	client := setupFakeClient(rt)
+
+	var v []int
+	err := client.NewPaginator(setupAuth(), "test/uri", nil).All(&v)
+	if err != nil || !reflect.DeepEqual(v, []int{1, 2}) {
+		t.Errorf("relative next link: returned %v, %v want %v", v, err, []int{1, 2})
+	}
+}
+
+func setupPageClient(pages int, key string) *Client {
+	return setupFakeClient(&fakeTransport{handler: paginated(pages, key)})
+}
+
+// paginated serves pages of [page, page] with Link headers up to pages
+// when key is set, each page is wrapped in an envelope under key
+func paginated(pages int, key string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		page, err := strconv.Atoi(req.URL.Query().Get("page"))
+		if err != nil {
+			page = 1
+		}
+
+		var body interface{} = []int{page, page}
+		if key != "" {
+			body = map[string]interface{}{"total_count": pages * 2, key: body}
+		}
+
+		link := func(p int, rel string) string {
+			u := *req.URL
+			q := u.Query()
+			q.Set("page", strconv.Itoa(p))
+			u.RawQuery = q.Encode()
+			return fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel)
+		}
+
+		h := w.Header()
+		if page < pages {
+			h.Add("Link", link(page+1, "next"))
+			h.Add("Link", link(pages, "last"))
+		}
+		if page > 1 {
+			h.Add("Link", link(page-1, "prev"))
+			h.Add("Link", link(1, "first"))
+		}
+
+		respondJSON(w, http.StatusOK, body)
+	}
+}
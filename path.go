@@ -0,0 +1,104 @@
+package crusch
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ExpandPath expands the parameters of a path template, escaping each value as a path segment
+// i.e. "/repos/{owner}/{repo}/contents/{path...}"
+// {name} is replaced by a single segment, slashes in the value are escaped
+// {name...} is a wildcard for values containing slashes such as file paths and refs, each segment is escaped
+// Values that are empty, or contain "." or ".." segments are rejected, as are parameters missing from params
+func ExpandPath(template string, params map[string]string) (string, error) {
+	var b strings.Builder
+
+	rest := template
+	for {
+		start := strings.Index(rest, "{")
+		if start < 0 {
+			if strings.Contains(rest, "}") {
+				return "", fmt.Errorf("invalid path template %s: unexpected }", template)
+			}
+			b.WriteString(rest)
+			return b.String(), nil
+		}
+
+		end := strings.Index(rest[start:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("invalid path template %s: unclosed {", template)
+		}
+		end += start
+
+		if strings.Contains(rest[:start], "}") {
+			return "", fmt.Errorf("invalid path template %s: unexpected }", template)
+		}
+		b.WriteString(rest[:start])
+
+		name := rest[start+1 : end]
+		wildcard := strings.HasSuffix(name, "...")
+		name = strings.TrimSuffix(name, "...")
+		if name == "" {
+			return "", fmt.Errorf("invalid path template %s: empty parameter name", template)
+		}
+
+		value, ok := params[name]
+		if !ok {
+			return "", fmt.Errorf("missing path parameter %s", name)
+		}
+
+		segment, err := escapePathParam(name, value, wildcard)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(segment)
+
+		rest = rest[end+1:]
+	}
+}
+
+// escapePathParam escapes the value of a path parameter
+// wildcard values are split on slashes and each segment escaped separately
+func escapePathParam(name string, value string, wildcard bool) (string, error) {
+	segments := []string{value}
+	if wildcard {
+		segments = strings.Split(value, "/")
+	}
+
+	for i, segment := range segments {
+		switch segment {
+		case "":
+			return "", fmt.Errorf("path parameter %s contains an empty segment", name)
+		case ".", "..":
+			return "", fmt.Errorf("path parameter %s contains a %s segment", name, segment)
+		}
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return strings.Join(segments, "/"), nil
+}
+
+// WithPathParams expands the uri of the request as a path template using params, see ExpandPath
+func WithPathParams(params map[string]string) RequestOption {
+	return func(o *requestOptions) {
+		o.pathParams = params
+	}
+}
+
+// expandRequestPath expands the path of req as a template using params
+func expandRequestPath(req *http.Request, params map[string]string) error {
+	escaped, err := ExpandPath(req.URL.Path, params)
+	if err != nil {
+		return err
+	}
+
+	path, err := url.PathUnescape(escaped)
+	if err != nil {
+		return fmt.Errorf("failed to unescape path: %v", err)
+	}
+
+	req.URL.Path, req.URL.RawPath = path, escaped
+	return nil
+}
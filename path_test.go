@@ -0,0 +1,66 @@
+package crusch
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestExpandPath(t *testing.T) {
+	template := "/repos/{owner}/{repo}/contents/{path...}"
+	cases := []struct {
+		name   string
+		params map[string]string
+		want   string
+		err    bool
+	}{
+		{"simple", map[string]string{"owner": "weavc", "repo": "crusch", "path": "readme.md"}, "/repos/weavc/crusch/contents/readme.md", false},
+		{"wildcard", map[string]string{"owner": "weavc", "repo": "crusch", "path": "docs/a file#1.md"}, "/repos/weavc/crusch/contents/docs/a%20file%231.md", false},
+		{"segment slash", map[string]string{"owner": "weavc/other", "repo": "crusch", "path": "a"}, "/repos/weavc%2Fother/crusch/contents/a", false},
+		{"query injection", map[string]string{"owner": "weavc", "repo": "crusch?ref=main", "path": "a"}, "/repos/weavc/crusch%3Fref=main/contents/a", false},
+		{"missing", map[string]string{"owner": "weavc", "repo": "crusch"}, "", true},
+		{"empty", map[string]string{"owner": "", "repo": "crusch", "path": "a"}, "", true},
+		{"dot dot", map[string]string{"owner": "..", "repo": "crusch", "path": "a"}, "", true},
+		{"wildcard dot dot", map[string]string{"owner": "weavc", "repo": "crusch", "path": "docs/../../../user"}, "", true},
+		{"wildcard empty segment", map[string]string{"owner": "weavc", "repo": "crusch", "path": "docs//a"}, "", true},
+	}
+
+	for _, c := range cases {
+		got, err := ExpandPath(template, c.params)
+		if c.err && err == nil {
+			t.Errorf("%s: unexpected nil error, returned %s", c.name, got)
+		}
+		if !c.err && (err != nil || got != c.want) {
+			t.Errorf("%s: returned %s, %v want %s", c.name, got, err, c.want)
+		}
+	}
+
+	for _, bad := range []string{"/repos/{owner", "/repos/owner}", "/repos/{}", "/repos/{...}"} {
+		_, err := ExpandPath(bad, map[string]string{"owner": "weavc"})
+		if err == nil {
+			t.Errorf("invalid template %s: unexpected nil error", bad)
+		}
+	}
+}
+
+func TestWithPathParams(t *testing.T) {
+	rt := &fakeTransport{}
+	client := NewGithubClient("doesnt.matter/api/v3", "http")
+	client.SetHTTPClient(&http.Client{Transport: rt})
+
+	_, err := client.Get(setupAuth(), "repos/{owner}/{repo}/git/ref/{ref...}", "a=b", nil,
+		WithPathParams(map[string]string{"owner": "weavc", "repo": "crusch", "ref": "heads/feature/a#b"}))
+	if err != nil {
+		t.Fatalf("path params: unexpected %v", err)
+	}
+
+	want := "http://doesnt.matter/api/v3/repos/weavc/crusch/git/ref/heads/feature/a%23b?a=b"
+	if got := rt.Request().URL.String(); got != want {
+		t.Errorf("path params: requested %s want %s", got, want)
+	}
+
+	_, err = client.Get(setupAuth(), "repos/{owner}/{repo}", nil, nil,
+		WithPathParams(map[string]string{"owner": "..", "repo": "crusch"}))
+	if err == nil {
+		t.Errorf("path params dot dot: unexpected nil error")
+	}
+}
@@ -0,0 +1,292 @@
+package crusch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weavc/crusch/internal"
+)
+
+// Rate limit resources Github tracks separately
+// https://docs.github.com/en/rest/rate-limit
+const (
+	ResourceCore                = "core"
+	ResourceSearch              = "search"
+	ResourceGraphQL             = "graphql"
+	ResourceIntegrationManifest = "integration_manifest"
+)
+
+// Rate is the rate limit state of a resource as reported by Github
+type Rate struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Used      int       `json:"used"`
+	Reset     time.Time `json:"reset"`
+	Resource  string    `json:"resource"`
+}
+
+// UnmarshalJSON decodes a rate from Githubs rate_limit response, where reset is a unix timestamp
+func (r *Rate) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Limit     int    `json:"limit"`
+		Remaining int    `json:"remaining"`
+		Used      int    `json:"used"`
+		Reset     int64  `json:"reset"`
+		Resource  string `json:"resource"`
+	}
+
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return err
+	}
+
+	*r = Rate{
+		Limit:     v.Limit,
+		Remaining: v.Remaining,
+		Used:      v.Used,
+		Reset:     internal.ParseUnix(v.Reset),
+		Resource:  v.Resource,
+	}
+	return nil
+}
+
+// RateLimits is the response from Githubs rate_limit endpoint
+// https://docs.github.com/en/rest/rate-limit#get-rate-limit-status-for-the-authenticated-user
+type RateLimits struct {
+	Resources map[string]Rate `json:"resources"`
+	Rate      Rate            `json:"rate"`
+}
+
+// SecondaryRateLimitError is returned when Github rejects a request because of its secondary rate limits
+// https://docs.github.com/en/rest/overview/resources-in-the-rest-api#secondary-rate-limits
+type SecondaryRateLimitError struct {
+	*ErrorResponse
+	// RetryAfter is how long Github asked us to wait before retrying
+	RetryAfter time.Duration
+}
+
+func (e *SecondaryRateLimitError) Error() string {
+	return fmt.Sprintf("secondary rate limit exceeded, retry after %v: %s", e.RetryAfter, e.Message)
+}
+
+// Unwrap returns the underlying ErrorResponse
+func (e *SecondaryRateLimitError) Unwrap() error {
+	return e.ErrorResponse
+}
+
+// SetSecondaryRateLimitRetry enables retrying requests which hit the secondary rate limits
+// The client will sleep for the requested delay and retry up to retries times,
+// provided the delay is no longer than maxWait
+// Requests with bodies that cannot be rewound are never retried
+func (c *Client) SetSecondaryRateLimitRetry(retries int, maxWait time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.secondaryRetries = retries
+	c.secondaryMaxWait = maxWait
+}
+
+// SetRateLimitWait sets whether the client should block until the rate limit resets
+// when the last known rate limit for the authorizer and resource has been exhausted
+// If false (default), requests are sent regardless
+func (c *Client) SetRateLimitWait(wait bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rateWait = wait
+}
+
+// RateLimit returns the last known rate limit for the authorizer and resource
+// ok is false if no request has reported a rate limit for them yet, or the authorizer doesn't implement Identifier
+func (c *Client) RateLimit(authorizer Authorizer, resource string) (rate Rate, ok bool) {
+	key, ok := rateKey(authorizer, resource)
+	if !ok {
+		return Rate{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rate, ok = c.rates[key]
+	return rate, ok
+}
+
+// GetRateLimits requests the current rate limits for the authorizer from Github
+// The returned limits are recorded by the client, this request does not count against the limit
+func (c *Client) GetRateLimits(authorizer Authorizer, options ...RequestOption) (*RateLimits, *Response, error) {
+	return c.GetRateLimitsContext(context.Background(), authorizer, options...)
+}
+
+// GetRateLimitsContext requests the current rate limits for the authorizer from Github using the given context
+// The returned limits are recorded by the client, this request does not count against the limit
+func (c *Client) GetRateLimitsContext(ctx context.Context, authorizer Authorizer, options ...RequestOption) (*RateLimits, *Response, error) {
+	v := &RateLimits{}
+	res, err := c.GetContext(ctx, authorizer, "rate_limit", nil, v, options...)
+	if err != nil {
+		return nil, res, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for resource, rate := range v.Resources {
+		c.setRate(authorizer, resource, rate)
+	}
+
+	return v, res, nil
+}
+
+// waitForRateLimit blocks until the rate limit for the requests resource resets
+// if rate limit waiting is enabled and the last known limit has been exhausted
+func (c *Client) waitForRateLimit(ctx context.Context, authorizer Authorizer, req *http.Request) error {
+	rate, ok := c.RateLimit(authorizer, c.resourceFor(req))
+
+	c.mu.Lock()
+	wait := c.rateWait
+	c.mu.Unlock()
+
+	if !wait || !ok || rate.Remaining > 0 {
+		return nil
+	}
+
+	d := time.Until(rate.Reset)
+	if d <= 0 {
+		return nil
+	}
+
+	return sleep(ctx, d)
+}
+
+// updateRateLimit records the rate limit headers from res
+func (c *Client) updateRateLimit(authorizer Authorizer, req *http.Request, res *http.Response) {
+	rate, ok := parseRate(res.Header)
+	if !ok {
+		return
+	}
+
+	if rate.Resource == "" {
+		rate.Resource = c.resourceFor(req)
+	}
+
+	id, ok := identity(authorizer)
+	if !ok {
+		return
+	}
+
+	c.getMetrics().SetRateLimit(id, rate.Resource, rate.Remaining, rate.Limit)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setRate(authorizer, rate.Resource, rate)
+}
+
+// setRate stores the rate for the authorizer and resource, mu must be held
+// Rates are not stored for authorizers without an identity
+func (c *Client) setRate(authorizer Authorizer, resource string, rate Rate) {
+	key, ok := rateKey(authorizer, resource)
+	if !ok {
+		return
+	}
+
+	if c.rates == nil {
+		c.rates = map[string]Rate{}
+	}
+	c.rates[key] = rate
+}
+
+// checkSecondaryRateLimit returns a SecondaryRateLimitError if e was caused by the secondary rate limits
+func checkSecondaryRateLimit(e *ErrorResponse) *SecondaryRateLimitError {
+	if e.StatusCode != http.StatusForbidden && e.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+
+	h := e.Response.Header
+	retryAfter := h.Get("Retry-After")
+	secondary := retryAfter != "" ||
+		strings.Contains(strings.ToLower(e.Message), "secondary rate limit") ||
+		strings.Contains(e.DocumentationURL, "secondary-rate-limits") ||
+		strings.Contains(e.DocumentationURL, "abuse")
+	if !secondary {
+		return nil
+	}
+
+	serr := &SecondaryRateLimitError{ErrorResponse: e}
+
+	// Github asks for at least a minute between retries when no delay is provided
+	serr.RetryAfter = time.Minute
+	if seconds, err := strconv.Atoi(retryAfter); err == nil {
+		serr.RetryAfter = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(retryAfter); err == nil {
+		serr.RetryAfter = time.Until(date)
+	} else if rate, ok := parseRate(h); ok && rate.Remaining == 0 {
+		serr.RetryAfter = time.Until(rate.Reset)
+	}
+
+	return serr
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// parseRate parses the X-RateLimit headers
+// ok is false if the headers are not present
+func parseRate(h http.Header) (rate Rate, ok bool) {
+	limit := h.Get("X-RateLimit-Limit")
+	if limit == "" {
+		return rate, false
+	}
+
+	rate.Limit, _ = strconv.Atoi(limit)
+	rate.Remaining, _ = strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	rate.Used, _ = strconv.Atoi(h.Get("X-RateLimit-Used"))
+	rate.Resource = h.Get("X-RateLimit-Resource")
+
+	reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err == nil {
+		rate.Reset = internal.ParseUnix(reset)
+	}
+
+	return rate, true
+}
+
+// resourceFor guesses the rate limit resource a request will count against from its path
+// The path is matched relative to the clients base path, so repositories named search or graphql count against core
+func (c *Client) resourceFor(req *http.Request) string {
+	g, err := url.Parse(c.graphQLURL())
+	if err == nil && strings.EqualFold(g.Host, req.URL.Host) && g.Path == req.URL.Path {
+		return ResourceGraphQL
+	}
+
+	path := req.URL.Path
+	if i := strings.Index(c.URL, "/"); i >= 0 {
+		path = strings.TrimPrefix(path, strings.TrimRight(c.URL[i:], "/"))
+	}
+
+	switch {
+	case strings.HasPrefix(path, "/search/"):
+		return ResourceSearch
+	case strings.HasPrefix(path, "/app-manifests/"):
+		return ResourceIntegrationManifest
+	default:
+		return ResourceCore
+	}
+}
+
+// rateKey returns the key of the authorizers rate limit for resource
+// ok is false if rate limits are not tracked for the authorizer, see Identifier
+func rateKey(authorizer Authorizer, resource string) (key string, ok bool) {
+	id, ok := identity(authorizer)
+	return id + "#" + resource, ok
+}
@@ -0,0 +1,251 @@
+package crusch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimitTracking(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Unix()
+	client := setupFakeClient(&fakeTransport{handler: rateLimited(5000, 4999, reset)})
+
+	auth := setupAuth()
+	if _, ok := client.RateLimit(auth, ResourceCore); ok {
+		t.Errorf("rate limit: unexpected rate before any requests")
+	}
+
+	_, err := client.Get(auth, "test/uri", nil, nil)
+	if err != nil {
+		t.Errorf("rate limit: unexpected %v", err)
+	}
+
+	rate, ok := client.RateLimit(auth, ResourceCore)
+	if !ok {
+		t.Fatalf("rate limit: rate was not recorded")
+	}
+
+	if rate.Limit != 5000 || rate.Remaining != 4999 || rate.Used != 1 || rate.Reset.Unix() != reset {
+		t.Errorf("rate limit: returned %+v", rate)
+	}
+
+	_, err = client.Get(auth, "search/issues", nil, nil)
+	if err != nil {
+		t.Errorf("rate limit search: unexpected %v", err)
+	}
+
+	if _, ok := client.RateLimit(auth, ResourceSearch); !ok {
+		t.Errorf("rate limit search: rate was not recorded")
+	}
+
+	other, _ := NewOAuth("anothertoken")
+	if _, ok := client.RateLimit(other, ResourceCore); ok {
+		t.Errorf("rate limit: rate recorded against a different authorizer")
+	}
+}
+
+func TestRateLimitUnidentified(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Unix()
+	rt := &fakeTransport{handler: func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Etag", `"abc"`)
+		rateLimited(5000, 0, reset)(w, req)
+	}}
+	client := setupFakeClient(rt)
+	client.SetRateLimitWait(true)
+	client.SetCache(NewMemoryCache(10))
+
+	// closures made by the same function share their code pointer, but not their token
+	auth := func(token string) Authorizer {
+		return AuthorizerFunc(func() (string, error) { return "token " + token, nil })
+	}
+	alice, bob := auth("alice"), auth("bob")
+
+	_, err := client.Get(alice, "test/uri", nil, nil)
+	if err != nil {
+		t.Fatalf("unidentified: unexpected %v", err)
+	}
+
+	if _, ok := client.RateLimit(alice, ResourceCore); ok {
+		t.Errorf("unidentified: rate recorded for an authorizer without an identity")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.GetContext(ctx, bob, "test/uri", nil, nil)
+	if err != nil || rt.Request().Header.Get("If-None-Match") != "" {
+		t.Errorf("unidentified: bob returned %v, sent %v", err, rt.Request().Header)
+	}
+}
+
+func TestGetRateLimits(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Unix()
+	body := map[string]interface{}{
+		"resources": map[string]interface{}{
+			"core":    map[string]interface{}{"limit": 5000, "remaining": 10, "used": 4990, "reset": reset, "resource": "core"},
+			"graphql": map[string]interface{}{"limit": 5000, "remaining": 20, "used": 4980, "reset": reset, "resource": "graphql"},
+		},
+		"rate": map[string]interface{}{"limit": 5000, "remaining": 10, "used": 4990, "reset": reset, "resource": "core"},
+	}
+	client := setupClient(body)
+
+	auth := setupAuth()
+	v, _, err := client.GetRateLimits(auth)
+	if err != nil {
+		t.Fatalf("rate limits: unexpected %v", err)
+	}
+
+	if v.Rate.Remaining != 10 || v.Rate.Reset.Unix() != reset {
+		t.Errorf("rate limits: returned %+v", v.Rate)
+	}
+
+	rate, ok := client.RateLimit(auth, ResourceGraphQL)
+	if !ok || rate.Remaining != 20 {
+		t.Errorf("rate limits: graphql rate %+v, recorded %v", rate, ok)
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Unix()
+	client := setupFakeClient(&fakeTransport{handler: rateLimited(5000, 0, reset)})
+	auth := setupAuth()
+
+	_, err := client.Get(auth, "test/uri", nil, nil)
+	if err != nil {
+		t.Errorf("rate limit wait: unexpected %v", err)
+	}
+
+	// waiting is disabled by default, the request should still be sent
+	_, err = client.Get(auth, "test/uri", nil, nil)
+	if err != nil {
+		t.Errorf("rate limit no wait: unexpected %v", err)
+	}
+
+	client.SetRateLimitWait(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = client.GetContext(ctx, auth, "test/uri", nil, nil)
+	if err != context.DeadlineExceeded {
+		t.Errorf("rate limit wait: returned %v want %v", err, context.DeadlineExceeded)
+	}
+
+	// other resources are unaffected
+	_, err = client.GetContext(ctx, auth, "search/issues", nil, nil)
+	if err != nil && err != context.DeadlineExceeded {
+		t.Errorf("rate limit wait search: unexpected %v", err)
+	}
+}
+
+// rateLimited responds with the X-RateLimit headers set to the given values
+func TestResourceFor(t *testing.T) {
+	github := NewGithubClient("api.github.com", "https")
+	enterprise, _ := NewEnterpriseClient("https://github.example.com")
+
+	cases := []struct {
+		client *Client
+		uri    string
+		want   string
+	}{
+		{github, "https://api.github.com/search/issues", ResourceSearch},
+		{github, "https://api.github.com/graphql", ResourceGraphQL},
+		{github, "https://api.github.com/app-manifests/abc/conversions", ResourceIntegrationManifest},
+		{github, "https://api.github.com/repos/acme/search/issues", ResourceCore},
+		{github, "https://api.github.com/repos/acme/graphql", ResourceCore},
+		{enterprise, "https://github.example.com/api/v3/search/code", ResourceSearch},
+		{enterprise, "https://github.example.com/api/graphql", ResourceGraphQL},
+		{enterprise, "https://github.example.com/api/v3/repos/acme/search/issues", ResourceCore},
+		{enterprise, "https://github.example.com/api/v3/graphql", ResourceCore},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest(http.MethodGet, c.uri, nil)
+		if got := c.client.resourceFor(req); got != c.want {
+			t.Errorf("resource for %s: returned %s want %s", c.uri, got, c.want)
+		}
+	}
+}
+
+func rateLimited(limit int, remaining int, reset int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		h := w.Header()
+		h.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		h.Set("X-RateLimit-Used", strconv.Itoa(limit-remaining))
+		h.Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		h.Set("X-RateLimit-Resource", NewGithubClient("doesnt.matter", "http").resourceFor(req))
+	}
+}
+
+func TestSecondaryRateLimit(t *testing.T) {
+	limited, retryAfter := 2, "0"
+	rt := &fakeTransport{}
+	rt.handler = func(w http.ResponseWriter, req *http.Request) {
+		if rt.Calls() > limited {
+			echo(w, req)
+			return
+		}
+
+		w.Header().Set("Retry-After", retryAfter)
+		respondJSON(w, http.StatusForbidden, map[string]string{
+			"message":           "You have exceeded a secondary rate limit",
+			"documentation_url": "https://docs.github.com/rest/overview/resources-in-the-rest-api#secondary-rate-limits",
+		})
+	}
+	client := setupFakeClient(rt)
+
+	_, err := client.Post(setupAuth(), "test/uri", &m{Weavc: "crusch", One: "1"}, nil)
+	var serr *SecondaryRateLimitError
+	if !errors.As(err, &serr) {
+		t.Fatalf("secondary rate limit: returned %v want *SecondaryRateLimitError", err)
+	}
+
+	if serr.RetryAfter != 0 || serr.Message != "You have exceeded a secondary rate limit" {
+		t.Errorf("secondary rate limit: returned %+v", serr)
+	}
+
+	rt.Reset()
+	client.SetSecondaryRateLimitRetry(2, time.Second)
+
+	var v m
+	_, err = client.Post(setupAuth(), "test/uri", &m{Weavc: "crusch", One: "1"}, &v)
+	if err != nil {
+		t.Errorf("secondary rate limit retry: unexpected %v", err)
+	}
+
+	if rt.Calls() != 3 {
+		t.Errorf("secondary rate limit retry: %d calls want %d", rt.Calls(), 3)
+	}
+
+	if v.Weavc != "crusch" {
+		t.Errorf("secondary rate limit retry: request body was not rewound, returned %v", v)
+	}
+
+	rt.Reset()
+	retryAfter = "120"
+
+	_, err = client.Get(setupAuth(), "test/uri", nil, nil)
+	if !errors.As(err, &serr) || rt.Calls() != 1 {
+		t.Errorf("secondary rate limit over max wait: returned %v after %d calls", err, rt.Calls())
+	}
+}
+
+func TestPrimaryRateLimitNotSecondary(t *testing.T) {
+	client := setupFakeClient(&fakeTransport{
+		status: http.StatusForbidden,
+		header: http.Header{"X-Ratelimit-Remaining": {"0"}},
+		body: map[string]string{
+			"message":           "API rate limit exceeded",
+			"documentation_url": "https://docs.github.com/rest/overview/resources-in-the-rest-api#rate-limiting",
+		},
+	})
+
+	_, err := client.Get(setupAuth(), "test/uri", nil, nil)
+	var serr *SecondaryRateLimitError
+	if err == nil || errors.As(err, &serr) {
+		t.Errorf("primary rate limit: returned %v", err)
+	}
+}
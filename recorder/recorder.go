@@ -0,0 +1,427 @@
+// Package recorder records the HTTP requests made through it to cassette files, and replays them
+// This allows tests against Githubs API to be recorded once with real credentials, then run offline
+//
+//	rec, err := recorder.New("testdata/issues.yaml", recorder.ModeReplayOrRecord)
+//	defer rec.Stop()
+//	client.SetHTTPClient(rec.HTTPClient())
+//
+// Authorization headers, tokens, JWTs, OAuth codes and secrets are scrubbed before cassettes are saved
+package recorder
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/weavc/crusch/internal"
+	"gopkg.in/yaml.v3"
+)
+
+// Mode controls whether a Recorder records or replays requests
+type Mode int
+
+const (
+	// ModeReplay replays requests from the cassette, requests without a recorded interaction fail
+	ModeReplay Mode = iota
+	// ModeRecord sends every request, replacing the cassette when the recorder is stopped
+	ModeRecord
+	// ModeReplayOrRecord replays if the cassette exists, otherwise it records
+	ModeReplayOrRecord
+)
+
+// Cassette is a list of recorded interactions
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions" yaml:"interactions"`
+}
+
+// Interaction is a recorded request and its response
+type Interaction struct {
+	Request  Request  `json:"request" yaml:"request"`
+	Response Response `json:"response" yaml:"response"`
+}
+
+// Request is a recorded request
+type Request struct {
+	Method string      `json:"method" yaml:"method"`
+	URL    string      `json:"url" yaml:"url"`
+	Header http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body   Body        `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+// Response is a recorded response
+type Response struct {
+	Status     string      `json:"status" yaml:"status"`
+	StatusCode int         `json:"status_code" yaml:"status_code"`
+	Header     http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body       Body        `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+// Body is a recorded body, bodies that are not valid UTF-8 are stored base64 encoded
+type Body struct {
+	String string `json:"string,omitempty" yaml:"string,omitempty"`
+	Base64 string `json:"base64,omitempty" yaml:"base64,omitempty"`
+}
+
+func newBody(b []byte) Body {
+	if utf8.Valid(b) {
+		return Body{String: string(b)}
+	}
+	return Body{Base64: base64.StdEncoding.EncodeToString(b)}
+}
+
+// Bytes returns the decoded body
+func (b Body) Bytes() ([]byte, error) {
+	if b.Base64 != "" {
+		return base64.StdEncoding.DecodeString(b.Base64)
+	}
+	return []byte(b.String), nil
+}
+
+// Matcher reports whether the recorded request matches req, body is the body of req
+type Matcher func(req *http.Request, body []byte, recorded *Request) bool
+
+// DefaultMatcher matches requests with the same method and url
+func DefaultMatcher(req *http.Request, body []byte, recorded *Request) bool {
+	return req.Method == recorded.Method && scrubURL(req.URL.String()) == recorded.URL
+}
+
+// BodyMatcher matches requests with the same method, url and body
+func BodyMatcher(req *http.Request, body []byte, recorded *Request) bool {
+	if !DefaultMatcher(req, body, recorded) {
+		return false
+	}
+
+	b, err := recorded.Body.Bytes()
+	return err == nil && bytes.Equal(scrubBody(body), b)
+}
+
+// Option configures a Recorder
+type Option func(*Recorder)
+
+// WithTransport sets the transport requests are sent with when recording, http.DefaultTransport by default
+func WithTransport(transport http.RoundTripper) Option {
+	return func(r *Recorder) {
+		r.transport = transport
+	}
+}
+
+// WithMatcher sets the Matcher used to find recorded interactions, DefaultMatcher by default
+func WithMatcher(matcher Matcher) Option {
+	return func(r *Recorder) {
+		r.matcher = matcher
+	}
+}
+
+// WithScrubber adds a function called with each interaction before it is saved
+// It can be used to remove values the default scrubbing does not know about
+func WithScrubber(scrubber func(*Interaction)) Option {
+	return func(r *Recorder) {
+		r.scrubbers = append(r.scrubbers, scrubber)
+	}
+}
+
+// Recorder is an http.RoundTripper recording or replaying requests from a cassette file
+// Cassettes ending with .json are stored as JSON, anything else as YAML
+type Recorder struct {
+	path      string
+	mode      Mode
+	transport http.RoundTripper
+	matcher   Matcher
+	scrubbers []func(*Interaction)
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// New creates a Recorder using the cassette at path
+// The cassette is loaded when replaying, it must exist for ModeReplay
+func New(path string, mode Mode, options ...Option) (*Recorder, error) {
+	r := &Recorder{
+		path:      path,
+		mode:      mode,
+		transport: http.DefaultTransport,
+		matcher:   DefaultMatcher,
+		cassette:  &Cassette{},
+	}
+	for _, option := range options {
+		option(r)
+	}
+
+	if r.mode == ModeReplayOrRecord {
+		r.mode = ModeRecord
+		if _, err := os.Stat(path); err == nil {
+			r.mode = ModeReplay
+		}
+	}
+
+	if r.mode == ModeReplay {
+		c, err := load(path)
+		if err != nil {
+			return nil, err
+		}
+		r.cassette = c
+		r.used = make([]bool, len(c.Interactions))
+	}
+
+	return r, nil
+}
+
+// Mode returns whether the recorder is recording or replaying
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// HTTPClient returns an http.Client using the recorder as its transport
+func (r *Recorder) HTTPClient() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip to implement http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %v", err)
+		}
+		body = b
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+
+	if r.mode == ModeReplay {
+		return r.replay(req, body)
+	}
+	return r.record(req, body)
+}
+
+// replay returns the response of the first unused interaction matching req
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || !r.matcher(req, body, &interaction.Request) {
+			continue
+		}
+		r.used[i] = true
+
+		b, err := interaction.Response.Body.Bytes()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode recorded body: %v", err)
+		}
+
+		return &http.Response{
+			Status:        interaction.Response.Status,
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        interaction.Response.Header.Clone(),
+			Body:          ioutil.NopCloser(bytes.NewReader(b)),
+			ContentLength: int64(len(b)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no recorded interaction for %s %s in %s", req.Method, scrubURL(req.URL.String()), r.path)
+}
+
+// record sends req, recording it and the response
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	res, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	interaction := &Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    scrubURL(req.URL.String()),
+			Header: scrubHeader(req.Header),
+			Body:   newBody(scrubBody(body)),
+		},
+		Response: Response{
+			Status:     res.Status,
+			StatusCode: res.StatusCode,
+			Header:     scrubHeader(res.Header),
+			Body:       newBody(scrubBody(b)),
+		},
+	}
+	for _, scrub := range r.scrubbers {
+		scrub(interaction)
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.mu.Unlock()
+
+	return res, nil
+}
+
+// Stop saves the cassette if the recorder is recording
+func (r *Recorder) Stop() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return save(r.path, r.cassette)
+}
+
+func isJSON(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+func load(path string) (*Cassette, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %v", err)
+	}
+
+	c := &Cassette{}
+	if isJSON(path) {
+		err = json.Unmarshal(b, c)
+	} else {
+		err = yaml.Unmarshal(b, c)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cassette %s: %v", path, err)
+	}
+
+	return c, nil
+}
+
+func save(path string, c *Cassette) error {
+	var b []byte
+	var err error
+	if isJSON(path) {
+		b, err = json.MarshalIndent(c, "", "  ")
+	} else {
+		b, err = yaml.Marshal(c)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %v", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create cassette directory: %v", err)
+	}
+
+	err = ioutil.WriteFile(path, b, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write cassette: %v", err)
+	}
+
+	return nil
+}
+
+// scrubbedHeaders are removed from recorded requests and responses
+var scrubbedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+func scrubHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, name := range scrubbedHeaders {
+		if _, ok := h[name]; ok {
+			h.Set(name, "[REDACTED]")
+		}
+	}
+	for name, values := range h {
+		for i := range values {
+			values[i] = internal.Redact(values[i])
+		}
+		h[name] = values
+	}
+	return h
+}
+
+func scrubURL(u string) string {
+	return internal.Redact(u)
+}
+
+// scrubbedKeys are the keys of JSON bodies whose values are removed, wherever they are in the body
+var scrubbedKeys = map[string]bool{"token": true, "access_token": true, "refresh_token": true, "client_secret": true}
+
+// scrubbedTopLevelKeys are only removed from the top level of JSON bodies
+// OAuth codes are sent at the top level, while errors use code for values such as missing_field
+var scrubbedTopLevelKeys = map[string]bool{"code": true}
+
+// scrubBody removes secrets from a body
+// JSON bodies are scrubbed by key, as tokens such as Github Enterprise Servers do not have a recognisable format
+// other text bodies, such as form bodies, are redacted as querystrings
+func scrubBody(b []byte) []byte {
+	if len(b) == 0 || !utf8.Valid(b) {
+		return b
+	}
+
+	// numbers are kept as json.Number so they are written back exactly as they were recorded
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if dec.Decode(&v) != nil {
+		return []byte(internal.Redact(string(b)))
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return []byte(internal.Redact(string(b)))
+	}
+
+	v, changed := scrubJSON(v, true)
+	if !changed {
+		return b
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if enc.Encode(v) != nil {
+		return []byte(internal.Redact(string(b)))
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// scrubJSON replaces the values of scrubbed keys in v and redacts tokens from its strings
+// it reports whether anything was changed
+func scrubJSON(v interface{}, top bool) (interface{}, bool) {
+	changed := false
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, value := range t {
+			if value != nil && (scrubbedKeys[k] || top && scrubbedTopLevelKeys[k]) {
+				t[k] = "[REDACTED]"
+				changed = true
+				continue
+			}
+
+			scrubbed, c := scrubJSON(value, false)
+			t[k], changed = scrubbed, changed || c
+		}
+	case []interface{}:
+		for i, value := range t {
+			scrubbed, c := scrubJSON(value, false)
+			t[i], changed = scrubbed, changed || c
+		}
+	case string:
+		redacted := internal.Redact(t)
+		return redacted, redacted != t
+	}
+	return v, changed
+}
@@ -0,0 +1,191 @@
+package recorder
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/weavc/crusch"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	for _, name := range []string{"cassette.yaml", "cassette.json"} {
+		path := filepath.Join(t.TempDir(), name)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/app/installations/2/access_tokens":
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"token": "ghs_secrettoken"}`))
+			case "/repos/weavc/crusch":
+				if r.Header.Get("Authorization") != "token ghs_secrettoken" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.Header().Set("X-GitHub-Request-Id", "ABCD:1234")
+				w.Write([]byte(`{"name": "crusch"}`))
+			case "/binary":
+				w.Write([]byte{0xff, 0xfe, 0x00})
+			}
+		}))
+
+		rec, err := New(path, ModeReplayOrRecord)
+		if err != nil || rec.Mode() != ModeRecord {
+			t.Fatalf("%s record: returned mode %v, %v", name, rec.Mode(), err)
+		}
+
+		client := crusch.NewGithubClient(strings.TrimPrefix(server.URL, "http://"), "http")
+		client.SetHTTPClient(rec.HTTPClient())
+		repo, binary := request(t, name+" record", client)
+
+		err = rec.Stop()
+		server.Close()
+		if err != nil {
+			t.Fatalf("%s record: unexpected %v", name, err)
+		}
+
+		b, _ := ioutil.ReadFile(path)
+		for _, secret := range []string{"ghs_secrettoken", "eyJ"} {
+			if strings.Contains(string(b), secret) {
+				t.Errorf("%s record: cassette contains %s\n%s", name, secret, b)
+			}
+		}
+
+		rec, err = New(path, ModeReplayOrRecord)
+		if err != nil || rec.Mode() != ModeReplay {
+			t.Fatalf("%s replay: returned mode %v, %v", name, rec.Mode(), err)
+		}
+		client.SetHTTPClient(rec.HTTPClient())
+
+		replayedRepo, replayedBinary := request(t, name+" replay", client)
+		if replayedRepo != repo || replayedBinary != binary {
+			t.Errorf("%s replay: returned %s %q want %s %q", name, replayedRepo, replayedBinary, repo, binary)
+		}
+
+		_, err = client.Get(crusch.AuthorizerFunc(func() (string, error) { return "token x", nil }), "repos/weavc/other", nil, nil)
+		if err == nil || !strings.Contains(err.Error(), "no recorded interaction") {
+			t.Errorf("%s replay, unrecorded: returned %v", name, err)
+		}
+	}
+}
+
+func request(t *testing.T, name string, client *crusch.Client) (string, string) {
+	key, err := crusch.RSAPrivateKeyFromPEMFile("../random_key.pem")
+	if err != nil {
+		t.Fatalf("%s: unexpected %v", name, err)
+	}
+
+	auth, _ := client.NewInstallationAuth(1, 2, key)
+	var v struct {
+		Name string `json:"name"`
+	}
+	res, err := client.Get(auth, "repos/weavc/crusch", nil, &v)
+	if err != nil || res.RequestID != "ABCD:1234" {
+		t.Fatalf("%s: returned %v, %v", name, res, err)
+	}
+
+	var binary string
+	_, err = client.Get(auth, "binary", nil, &binary)
+	if err != nil {
+		t.Fatalf("%s binary: unexpected %v", name, err)
+	}
+
+	return v.Name, binary
+}
+
+func TestRecordUnprefixedTokens(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/app/installations/2/access_tokens":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"token":"v1.6e0fa5c1e2b3d4f5a6b7c8d9e0f1a2b3c4d5e6f7","expires_at":"2030-01-01T00:00:00Z"}`))
+		case "/login/oauth/access_token":
+			w.Write([]byte(`{"access_token":"e72e16c7e42f292c6912e7710c838347ae178b4a","refresh_token":"r1.c1b4a2e3","token_type":"bearer"}`))
+		case "/api/v3/repos/weavc/crusch/issues":
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"message":"Validation Failed","errors":[{"resource":"Issue","field":"title","code":"missing_field"}]}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message":"the token expired yesterday"}`))
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.yaml")
+	rec, _ := New(path, ModeRecord)
+	client := crusch.NewGithubClient(strings.TrimPrefix(server.URL, "http://")+"/api/v3", "http")
+	client.SetHTTPClient(rec.HTTPClient())
+	auth := crusch.AuthorizerFunc(func() (string, error) { return "token v1.6e0fa5c1e2b3d4f5a6b7c8d9e0f1a2b3c4d5e6f7", nil })
+
+	client.Post(auth, "app/installations/2/access_tokens", nil, nil)
+	client.Post(auth, "repos/weavc/crusch/issues", map[string]string{"body": "no title"}, nil)
+	client.Get(auth, "user", nil, nil)
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/login/oauth/access_token",
+		strings.NewReader(`{"client_id":"Iv1.abc","client_secret":"0123456789abcdef","code":"5d8e6c2f1a"}`))
+	res, err := rec.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("oauth access token: unexpected %v", err)
+	}
+	res.Body.Close()
+
+	err = rec.Stop()
+	if err != nil {
+		t.Fatalf("record: unexpected %v", err)
+	}
+
+	b, _ := ioutil.ReadFile(path)
+	for _, secret := range []string{"6e0fa5c1e2b3", "e72e16c7e42f", "r1.c1b4a2e3", "0123456789abcdef", "5d8e6c2f1a"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("record: cassette contains %s\n%s", secret, b)
+		}
+	}
+	for _, kept := range []string{"the token expired yesterday", "missing_field", "Iv1.abc", "2030-01-01T00:00:00Z"} {
+		if !strings.Contains(string(b), kept) {
+			t.Errorf("record: cassette is missing %s\n%s", kept, b)
+		}
+	}
+}
+
+func TestScrubBody(t *testing.T) {
+	cases := map[string]string{
+		`{"token":"v1.abc","expires_at":"2030"}`:             `{"expires_at":"2030","token":"[REDACTED]"}`,
+		`{"access_token":"e72e","token_type":"bearer"}`:      `{"access_token":"[REDACTED]","token_type":"bearer"}`,
+		`[{"name":"a","secret":{"client_secret":"shh"}}]`:    `[{"name":"a","secret":{"client_secret":"[REDACTED]"}}]`,
+		`{"code":"abc","errors":[{"code":"missing_field"}]}`: `{"code":"[REDACTED]","errors":[{"code":"missing_field"}]}`,
+		`{"message":"the token expired", "token": null}`:     `{"message":"the token expired", "token": null}`,
+		`{"body":"<b>using ghp_xyz</b>"}`:                    `{"body":"<b>using [REDACTED]</b>"}`,
+		`client_id=1&client_secret=shh&code=abc`:             `client_id=1&client_secret=[REDACTED]&code=[REDACTED]`,
+		`the token expired`:                                  `the token expired`,
+		`{"id":12345678901234567891,"size":1.0,"token":"a"}`: `{"id":12345678901234567891,"size":1.0,"token":"[REDACTED]"}`,
+		`{"token":"a"} trailing`:                             `{"token":"a"} trailing`,
+	}
+
+	for in, want := range cases {
+		if got := string(scrubBody([]byte(in))); got != want {
+			t.Errorf("scrub %s: returned %s want %s", in, got, want)
+		}
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	recorded := &Request{Method: http.MethodPost, URL: "https://api.github.com/login/oauth/access_token?code=[REDACTED]", Body: Body{String: `{"a":1}`}}
+
+	req, _ := http.NewRequest(http.MethodPost, "https://api.github.com/login/oauth/access_token?code=abc", nil)
+	if !DefaultMatcher(req, nil, recorded) {
+		t.Errorf("default matcher: scrubbed url did not match")
+	}
+	if BodyMatcher(req, []byte(`{"a":2}`), recorded) {
+		t.Errorf("body matcher: different body matched")
+	}
+	if !BodyMatcher(req, []byte(`{"a":1}`), recorded) {
+		t.Errorf("body matcher: same body did not match")
+	}
+
+	_, err := New(filepath.Join(t.TempDir(), "missing.yaml"), ModeReplay)
+	if err == nil {
+		t.Errorf("replay missing cassette: unexpected nil error")
+	}
+}
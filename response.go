@@ -0,0 +1,88 @@
+package crusch
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weavc/crusch/internal"
+)
+
+// Response wraps the http.Response of a request, exposing the metadata Github provides in its headers
+type Response struct {
+	*http.Response
+
+	// Links are the pagination links of the response
+	Links Links
+	// Rate is the rate limit reported by the response, the zero value if it was not included
+	Rate Rate
+	// RequestID is the X-GitHub-Request-Id, useful when contacting Github support
+	RequestID string
+	// OAuthScopes are the scopes the token used has been granted
+	OAuthScopes []string
+	// AcceptedOAuthScopes are the scopes the endpoint accepts
+	AcceptedOAuthScopes []string
+	// Deprecated is true if the endpoint has been deprecated, Deprecation is when if provided
+	Deprecated  bool
+	Deprecation time.Time
+	// Sunset is when the endpoint will be removed, the zero value if not provided
+	Sunset time.Time
+	// APIVersion is the REST API version used to serve the request
+	APIVersion string
+}
+
+// newResponse wraps res in a Response, parsing its headers
+func newResponse(res *http.Response) *Response {
+	if res == nil {
+		return nil
+	}
+
+	r := &Response{
+		Response:            res,
+		Links:               ParseLinks(res.Header),
+		RequestID:           res.Header.Get("X-GitHub-Request-Id"),
+		OAuthScopes:         parseScopes(res.Header.Get("X-OAuth-Scopes")),
+		AcceptedOAuthScopes: parseScopes(res.Header.Get("X-Accepted-OAuth-Scopes")),
+		APIVersion:          res.Header.Get("X-GitHub-Api-Version-Selected"),
+	}
+
+	r.Rate, _ = parseRate(res.Header)
+
+	if deprecation := res.Header.Get("Deprecation"); deprecation != "" {
+		r.Deprecated = deprecation != "false"
+		r.Deprecation = parseHeaderTime(deprecation)
+	}
+	r.Sunset = parseHeaderTime(res.Header.Get("Sunset"))
+
+	return r
+}
+
+// parseScopes parses a comma separated list of scopes
+func parseScopes(scopes string) []string {
+	if strings.TrimSpace(scopes) == "" {
+		return nil
+	}
+
+	s := strings.Split(scopes, ",")
+	for i := range s {
+		s[i] = strings.TrimSpace(s[i])
+	}
+	return s
+}
+
+// parseHeaderTime parses an HTTP date or @unix timestamp, returning the zero time if neither
+func parseHeaderTime(v string) time.Time {
+	if strings.HasPrefix(v, "@") {
+		unix, err := strconv.ParseInt(v[1:], 10, 64)
+		if err == nil {
+			return internal.ParseUnix(unix)
+		}
+	}
+
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
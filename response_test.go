@@ -0,0 +1,73 @@
+package crusch
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResponse(t *testing.T) {
+	h := http.Header{}
+	h.Set("Link", `<https://api.github.com/x?page=2>; rel="next"`)
+	h.Set("X-RateLimit-Limit", "5000")
+	h.Set("X-RateLimit-Remaining", "4990")
+	h.Set("X-RateLimit-Reset", "1700000000")
+	h.Set("X-OAuth-Scopes", "repo, user")
+	h.Set("X-Accepted-OAuth-Scopes", "repo")
+	h.Set("Deprecation", "@1688169599")
+	h.Set("Sunset", "Sat, 01 Jun 2024 00:00:00 GMT")
+	h.Set("X-GitHub-Api-Version-Selected", "2022-11-28")
+	h.Set("X-GitHub-Request-Id", "ABCD:1234")
+	client := setupFakeClient(&fakeTransport{header: h, body: "{}"})
+
+	res, err := client.Get(setupAuth(), "test/uri", nil, nil)
+	if err != nil {
+		t.Fatalf("response: unexpected %v", err)
+	}
+
+	if res.StatusCode != 200 {
+		t.Errorf("response: status %d want %d", res.StatusCode, 200)
+	}
+
+	if res.Links.Next != "https://api.github.com/x?page=2" {
+		t.Errorf("response: links %v", res.Links)
+	}
+
+	if res.Rate.Limit != 5000 || res.Rate.Remaining != 4990 || res.Rate.Reset.Unix() != 1700000000 {
+		t.Errorf("response: rate %+v", res.Rate)
+	}
+
+	if res.RequestID != "ABCD:1234" {
+		t.Errorf("response: request id %s", res.RequestID)
+	}
+
+	if !reflect.DeepEqual(res.OAuthScopes, []string{"repo", "user"}) || !reflect.DeepEqual(res.AcceptedOAuthScopes, []string{"repo"}) {
+		t.Errorf("response: scopes %v accepted %v", res.OAuthScopes, res.AcceptedOAuthScopes)
+	}
+
+	if !res.Deprecated || res.Deprecation.Unix() != 1688169599 {
+		t.Errorf("response: deprecation %v %v", res.Deprecated, res.Deprecation)
+	}
+
+	if !res.Sunset.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("response: sunset %v", res.Sunset)
+	}
+
+	if res.APIVersion != "2022-11-28" {
+		t.Errorf("response: api version %s", res.APIVersion)
+	}
+}
+
+func TestResponseNoMetadata(t *testing.T) {
+	client := setupClient(generalResponse)
+
+	res, err := client.Get(setupAuth(), "test/uri", nil, nil)
+	if err != nil {
+		t.Fatalf("response: unexpected %v", err)
+	}
+
+	if res.Deprecated || !res.Sunset.IsZero() || res.OAuthScopes != nil || res.Rate.Limit != 0 {
+		t.Errorf("response: unexpected metadata %+v", res)
+	}
+}
@@ -0,0 +1,157 @@
+package crusch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how a client retries requests which failed transiently
+// A nil policy (default) disables retries
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts made, including the first
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry, it is doubled for each attempt after
+	MinBackoff time.Duration
+	// MaxBackoff is the maximum delay between attempts
+	MaxBackoff time.Duration
+	// Jitter is the fraction (0 to 1) of each delay which is randomised
+	Jitter float64
+	// Statuses are the response status codes which are retried
+	Statuses []int
+	// Methods are the request methods which are retried
+	Methods []string
+	// RetryError reports whether a request error should be retried
+	// IsTransientError is used if nil
+	RetryError func(err error) bool
+}
+
+// DefaultRetryPolicy returns a policy which retries idempotent requests up to 3 times
+// on 502, 503 and 504 responses and transient connection errors
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  500 * time.Millisecond,
+		MaxBackoff:  10 * time.Second,
+		Jitter:      0.2,
+		Statuses: []int{
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		Methods: []string{
+			http.MethodGet,
+			http.MethodHead,
+			http.MethodOptions,
+			http.MethodPut,
+			http.MethodDelete,
+		},
+	}
+}
+
+// SetRetryPolicy sets the policy used to retry failed requests, nil disables retries
+func (c *Client) SetRetryPolicy(policy *RetryPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.retryPolicy = policy
+}
+
+// RetryError is returned when a request has failed after being attempted more than once
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("request failed after %d attempts: %v", e.Attempts, e.Err)
+}
+
+// Unwrap returns the error from the final attempt
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// IsTransientError reports whether err is a connection error that is likely to succeed if retried
+func IsTransientError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) {
+		return true
+	}
+
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
+
+// retryable reports whether the request should be retried after receiving res or err
+func (p *RetryPolicy) retryable(req *http.Request, res *http.Response, err error) bool {
+	if p == nil {
+		return false
+	}
+
+	if !p.allowsMethod(req.Method) {
+		return false
+	}
+
+	if res == nil {
+		if p.RetryError != nil {
+			return p.RetryError(err)
+		}
+		return IsTransientError(err)
+	}
+
+	for _, status := range p.Statuses {
+		if res.StatusCode == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *RetryPolicy) allowsMethod(method string) bool {
+	for _, m := range p.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the delay before the next attempt, after the given number of attempts
+func (p *RetryPolicy) backoff(attempts int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < attempts && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 {
+		d -= time.Duration(p.Jitter * rand.Float64() * float64(d))
+	}
+
+	return d
+}
+
+// withAttempts wraps err in a RetryError if more than one attempt was made
+func withAttempts(attempts int, err error) error {
+	if attempts <= 1 {
+		return err
+	}
+	return &RetryError{Attempts: attempts, Err: err}
+}
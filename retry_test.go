@@ -0,0 +1,116 @@
+package crusch
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	failures := 2
+	rt := &fakeTransport{}
+	rt.handler = func(w http.ResponseWriter, req *http.Request) {
+		if rt.Calls() <= failures {
+			respondJSON(w, http.StatusServiceUnavailable, map[string]string{"message": "unavailable"})
+			return
+		}
+		echo(w, req)
+	}
+	client := setupFakeClient(rt)
+
+	_, err := client.Get(setupAuth(), "test/uri", nil, nil)
+	if err == nil || rt.Calls() != 1 {
+		t.Errorf("no retry policy: returned %v after %d calls", err, rt.Calls())
+	}
+
+	rt.Reset()
+	client.SetRetryPolicy(testRetryPolicy())
+
+	var v m
+	_, err = client.Put(setupAuth(), "test/uri", &m{Weavc: "crusch", One: "1"}, &v)
+	if err != nil {
+		t.Errorf("retry put: unexpected %v", err)
+	}
+
+	if rt.Calls() != 3 {
+		t.Errorf("retry put: %d calls want %d", rt.Calls(), 3)
+	}
+
+	if v.Weavc != "crusch" || v.One != "1" {
+		t.Errorf("retry put: request body was not rewound, returned %v", v)
+	}
+
+	rt.Reset()
+	_, err = client.Post(setupAuth(), "test/uri", &m{Weavc: "crusch", One: "1"}, &v)
+	if err == nil || rt.Calls() != 1 {
+		t.Errorf("retry post: non idempotent method returned %v after %d calls", err, rt.Calls())
+	}
+
+	rt.Reset()
+	failures = 5
+	_, err = client.Get(setupAuth(), "test/uri", nil, nil)
+
+	var rerr *RetryError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("retry exhausted: returned %v want *RetryError", err)
+	}
+
+	if rerr.Attempts != 3 || rt.Calls() != 3 {
+		t.Errorf("retry exhausted: %d attempts, %d calls want %d", rerr.Attempts, rt.Calls(), 3)
+	}
+
+	if !strings.Contains(rerr.Error(), "after 3 attempts") {
+		t.Errorf("retry exhausted: error %q does not contain the attempt count", rerr.Error())
+	}
+}
+
+func TestRetryTransientError(t *testing.T) {
+	rt := &fakeTransport{errors: 1, err: syscall.ECONNRESET, handler: echo}
+	client := setupFakeClient(rt)
+	client.SetRetryPolicy(testRetryPolicy())
+
+	_, err := client.Get(setupAuth(), "test/uri", nil, nil)
+	if err != nil {
+		t.Errorf("retry connection reset: unexpected %v", err)
+	}
+
+	if rt.Calls() != 2 {
+		t.Errorf("retry connection reset: %d calls want %d", rt.Calls(), 2)
+	}
+
+	rt.Reset()
+	rt.err = errors.New("permanent")
+	_, err = client.Get(setupAuth(), "test/uri", nil, nil)
+	if err == nil || rt.Calls() != 1 {
+		t.Errorf("permanent error: returned %v after %d calls", err, rt.Calls())
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := &RetryPolicy{MinBackoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if d := p.backoff(i + 1); d != w {
+			t.Errorf("backoff attempt %d: returned %v want %v", i+1, d, w)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 1; i < 5; i++ {
+		d := p.backoff(i)
+		if d < want[i-1]/2 || d > want[i-1] {
+			t.Errorf("backoff jitter attempt %d: returned %v outside %v-%v", i, d, want[i-1]/2, want[i-1])
+		}
+	}
+}
+
+func testRetryPolicy() *RetryPolicy {
+	p := DefaultRetryPolicy()
+	p.MinBackoff = time.Millisecond
+	p.MaxBackoff = time.Millisecond
+	return p
+}
@@ -0,0 +1,111 @@
+package crusch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/weavc/crusch/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/weavc/crusch"
+
+// SetTracerProvider sets the OpenTelemetry TracerProvider used to trace requests, nil (default) disables tracing
+// A span is created for each request as a child of the span in the requests context,
+// and for the access token requests made by InstallationAuth
+// i.e. client.SetTracerProvider(otel.GetTracerProvider())
+func (c *Client) SetTracerProvider(provider trace.TracerProvider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tracer = nil
+	if provider != nil {
+		c.tracer = provider.Tracer(tracerName)
+	}
+}
+
+// WithTracerProvider returns a clone of the client traced using provider
+func (c *Client) WithTracerProvider(provider trace.TracerProvider) *Client {
+	clone := c.Clone()
+	clone.SetTracerProvider(provider)
+	return clone
+}
+
+func (c *Client) getTracer() trace.Tracer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tracer
+}
+
+// startSpan starts a span named name if the client is traced, returning the context to continue with
+// the returned span is a no-op span if the client is not traced
+func (c *Client) startSpan(ctx context.Context, name string, kind trace.SpanKind, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := c.getTracer()
+	if tracer == nil {
+		return ctx, trace.SpanFromContext(context.Background())
+	}
+
+	return tracer.Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
+
+// startRequestSpan starts the span of req, replacing its context with one containing the span
+func (c *Client) startRequestSpan(req *Request) trace.Span {
+	// spans of requests without a route are named by their method alone
+	name := req.Method
+	attrs := []attribute.KeyValue{
+		attribute.String("http.request.method", req.Method),
+		attribute.String("server.address", req.URL.Hostname()),
+	}
+	if req.Route != RouteUnknown {
+		name = fmt.Sprintf("%s %s", req.Method, req.Route)
+		attrs = append(attrs, attribute.String("http.route", req.Route))
+	}
+
+	ctx, span := c.startSpan(req.Context(), name, trace.SpanKindClient, attrs...)
+
+	if span.IsRecording() {
+		req.Request = req.WithContext(ctx)
+	}
+	return span
+}
+
+// endRequestSpan records the outcome of the request on span and ends it
+func endRequestSpan(span trace.Span, res *Response, err error) {
+	if !span.IsRecording() {
+		return
+	}
+	defer span.End()
+
+	if res != nil && res.Response != nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", res.StatusCode))
+		if res.RequestID != "" {
+			span.SetAttributes(attribute.String("github.request_id", res.RequestID))
+		}
+		if res.Rate.Limit > 0 {
+			span.SetAttributes(
+				attribute.Int("github.rate_limit.remaining", res.Rate.Remaining),
+				attribute.String("github.rate_limit.resource", res.Rate.Resource),
+			)
+		}
+	}
+
+	recordSpanError(span, err)
+}
+
+// recordSpanError records err on span, redacting any tokens it contains
+func recordSpanError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+
+	msg := internal.Redact(err.Error())
+	span.RecordError(errors.New(msg))
+	span.SetStatus(codes.Error, msg)
+
+	var retryErr *RetryError
+	if errors.As(err, &retryErr) {
+		span.SetAttributes(attribute.Int("github.attempts", retryErr.Attempts))
+	}
+}
@@ -0,0 +1,89 @@
+package crusch
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	header := http.Header{}
+	header.Set("X-GitHub-Request-Id", "ABCD:1234")
+	header.Set("X-RateLimit-Limit", "5000")
+	header.Set("X-RateLimit-Remaining", "4999")
+	client := setupFakeClient(&fakeTransport{header: header, body: `{"token": "ghs_secrettoken"}`})
+	client.SetTracerProvider(provider)
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "webhook")
+	auth, _ := client.NewInstallationAuth(1, 2, getKey())
+	_, err := client.GetContext(ctx, auth, "repos/{owner}/{repo}", nil, nil,
+		WithPathParams(map[string]string{"owner": "weavc", "repo": "crusch"}))
+	parent.End()
+	if err != nil {
+		t.Fatalf("tracing: unexpected %v", err)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+
+	request, token, exchange := spans["GET /repos/{owner}/{repo}"], spans["github installation token"], spans["POST /app/installations/{installation_id}/access_tokens"]
+	if request == nil || token == nil || exchange == nil {
+		t.Fatalf("tracing: recorded spans %v", spans)
+	}
+
+	if request.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("tracing: request span is not a child of the context span")
+	}
+	if token.Parent().SpanID() != request.SpanContext().SpanID() || exchange.Parent().SpanID() != token.SpanContext().SpanID() {
+		t.Errorf("tracing: token span is not a child of the request span")
+	}
+
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, a := range request.Attributes() {
+		attrs[a.Key] = a.Value
+	}
+
+	if attrs["http.route"].AsString() != "/repos/{owner}/{repo}" || attrs["http.response.status_code"].AsInt64() != 200 ||
+		attrs["github.request_id"].AsString() != "ABCD:1234" || attrs["github.rate_limit.remaining"].AsInt64() != 4999 {
+		t.Errorf("tracing: request attributes %v", attrs)
+	}
+
+	recorder = tracetest.NewSpanRecorder()
+	failing := setupFakeClient(&fakeTransport{status: 404, body: `{"message": "Not Found"}`})
+	failing.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	failing.Get(setupAuth(), "user?access_token=gho_secret", nil, nil)
+
+	ended := recorder.Ended()
+	if len(ended) != 1 || ended[0].Status().Code != codes.Error || strings.Contains(ended[0].Status().Description, "gho_secret") {
+		t.Fatalf("tracing failure: recorded %v", ended)
+	}
+
+	if ended[0].Name() != "GET" {
+		t.Errorf("tracing failure: recorded span %s want GET", ended[0].Name())
+	}
+	for _, a := range ended[0].Attributes() {
+		if a.Key == "http.route" {
+			t.Errorf("tracing failure: recorded http.route %s without path params", a.Value.AsString())
+		}
+	}
+}
+
+func TestTracingDisabled(t *testing.T) {
+	client := setupClient(generalResponse)
+	_, span := client.startSpan(context.Background(), "disabled", trace.SpanKindClient)
+	if span.IsRecording() {
+		t.Errorf("tracing disabled: span is recording")
+	}
+}
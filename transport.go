@@ -11,7 +11,7 @@ type transport struct {
 }
 
 func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
-	h, err := t.authorizer.GetHeader()
+	h, err := getHeader(req.Context(), t.authorizer)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get authorization header: %v", err)
 	}
@@ -23,6 +23,7 @@ func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
 
 // AttachAuthorizer attaches a new http.Transport layer that adds authorization headers to the request
 // this new layer wraps any existing transport layers
+// the requests context is passed through to authorizers implementing ContextAuthorizer
 // this can be used in conjuction with go-github to provide authorization headers to requests
 func AttachAuthorizer(authorizer Authorizer, httpClient *http.Client) error {
 	ct := transport{authorizer, httpClient.Transport}
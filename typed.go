@@ -0,0 +1,124 @@
+package crusch
+
+import (
+	"context"
+	"iter"
+	"net/http"
+)
+
+// Get makes a GET request using c, returning the response bound to a new T
+// i.e. repo, res, err := crusch.Get[Repository](client, authorizer, "repos/weavc/crusch", nil)
+func Get[T any](c *Client, authorizer Authorizer, uri string, params interface{}, options ...RequestOption) (T, *Response, error) {
+	return GetContext[T](context.Background(), c, authorizer, uri, params, options...)
+}
+
+// GetContext is Get using the given context
+func GetContext[T any](ctx context.Context, c *Client, authorizer Authorizer, uri string, params interface{}, options ...RequestOption) (T, *Response, error) {
+	var v T
+	res, err := c.GetContext(ctx, authorizer, uri, params, &v, options...)
+	return v, res, err
+}
+
+// Post makes a POST request using c, returning the response bound to a new T
+func Post[T any](c *Client, authorizer Authorizer, uri string, body interface{}, options ...RequestOption) (T, *Response, error) {
+	return PostContext[T](context.Background(), c, authorizer, uri, body, options...)
+}
+
+// PostContext is Post using the given context
+func PostContext[T any](ctx context.Context, c *Client, authorizer Authorizer, uri string, body interface{}, options ...RequestOption) (T, *Response, error) {
+	return doTyped[T](ctx, c, http.MethodPost, authorizer, uri, body, options)
+}
+
+// Put makes a PUT request using c, returning the response bound to a new T
+func Put[T any](c *Client, authorizer Authorizer, uri string, body interface{}, options ...RequestOption) (T, *Response, error) {
+	return PutContext[T](context.Background(), c, authorizer, uri, body, options...)
+}
+
+// PutContext is Put using the given context
+func PutContext[T any](ctx context.Context, c *Client, authorizer Authorizer, uri string, body interface{}, options ...RequestOption) (T, *Response, error) {
+	return doTyped[T](ctx, c, http.MethodPut, authorizer, uri, body, options)
+}
+
+// Patch makes a PATCH request using c, returning the response bound to a new T
+func Patch[T any](c *Client, authorizer Authorizer, uri string, body interface{}, options ...RequestOption) (T, *Response, error) {
+	return PatchContext[T](context.Background(), c, authorizer, uri, body, options...)
+}
+
+// PatchContext is Patch using the given context
+func PatchContext[T any](ctx context.Context, c *Client, authorizer Authorizer, uri string, body interface{}, options ...RequestOption) (T, *Response, error) {
+	return doTyped[T](ctx, c, http.MethodPatch, authorizer, uri, body, options)
+}
+
+func doTyped[T any](ctx context.Context, c *Client, method string, authorizer Authorizer, uri string, body interface{}, options []RequestOption) (T, *Response, error) {
+	var v T
+	res, err := c.doWithBody(ctx, method, authorizer, uri, body, &v, options)
+	return v, res, err
+}
+
+// Items iterates over every item of the remaining pages of p, requesting pages as they are reached
+// Each page must be a list of T, or an envelope of them when Key is set
+// An error ends the iteration after it is yielded
+// i.e. for issue, err := range crusch.Items[Issue](client.NewPaginator(authorizer, "repos/weavc/crusch/issues", nil))
+func Items[T any](p *Paginator) iter.Seq2[T, error] {
+	return ItemsContext[T](context.Background(), p)
+}
+
+// ItemsContext is Items using the given context
+func ItemsContext[T any](ctx context.Context, p *Paginator) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for page, err := range PagesContext[T](ctx, p) {
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+
+			for _, item := range page {
+				if !yield(item, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Pages iterates over the remaining pages of p, yielding the items of each page
+// The response of the last page retrieved is available from p.Links and p.TotalCount
+func Pages[T any](p *Paginator) iter.Seq2[[]T, error] {
+	return PagesContext[T](context.Background(), p)
+}
+
+// PagesContext is Pages using the given context
+func PagesContext[T any](ctx context.Context, p *Paginator) iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		for p.HasNext() {
+			var page []T
+			_, err := p.NextContext(ctx, &page)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			if !yield(page, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Collect retrieves every remaining item of p
+func Collect[T any](p *Paginator) ([]T, error) {
+	return CollectContext[T](context.Background(), p)
+}
+
+// CollectContext is Collect using the given context
+func CollectContext[T any](ctx context.Context, p *Paginator) ([]T, error) {
+	var items []T
+	for item, err := range ItemsContext[T](ctx, p) {
+		if err != nil {
+			return items, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
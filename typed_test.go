@@ -0,0 +1,82 @@
+package crusch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTypedRequests(t *testing.T) {
+	client := setupClient(generalResponse)
+	want := m{Weavc: "crusch", One: "1"}
+
+	v, res, err := Get[m](client, setupAuth(), "test/uri", nil)
+	if err != nil || res.StatusCode != 200 || v != want {
+		t.Errorf("typed get: returned %v, %v want %v", v, err, want)
+	}
+
+	v, _, err = Post[m](client, setupAuth(), "test/uri", want)
+	if err != nil || v != want {
+		t.Errorf("typed post: returned %v, %v want %v", v, err, want)
+	}
+
+	v, _, err = Put[m](client, setupAuth(), "test/uri", want)
+	if err != nil || v != want {
+		t.Errorf("typed put: returned %v, %v want %v", v, err, want)
+	}
+
+	p, _, err := Patch[map[string]string](client, setupAuth(), "test/uri", want)
+	if err != nil || !reflect.DeepEqual(p, generalResponse) {
+		t.Errorf("typed patch: returned %v, %v want %v", p, err, generalResponse)
+	}
+
+	_, _, err = Get[[]m](client, setupAuth(), "test/uri", nil)
+	if err == nil {
+		t.Errorf("typed get, bad binding: unexpected nil error")
+	}
+}
+
+func TestItems(t *testing.T) {
+	client := setupPageClient(3, "")
+
+	var items []int
+	for item, err := range Items[int](client.NewPaginator(setupAuth(), "test/uri", nil)) {
+		if err != nil {
+			t.Fatalf("items: unexpected %v", err)
+		}
+		items = append(items, item)
+	}
+
+	want := []int{1, 1, 2, 2, 3, 3}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("items: returned %v want %v", items, want)
+	}
+
+	// stopping early should not request further pages
+	p := client.NewPaginator(setupAuth(), "test/uri", nil)
+	for item := range Items[int](p) {
+		if item == 1 {
+			break
+		}
+	}
+	if p.Links.Prev != "" {
+		t.Errorf("items break: paginator moved past the first page %v", p.Links)
+	}
+
+	pages := 0
+	for page, err := range Pages[int](setupPageClient(2, "items").NewPaginator(setupAuth(), "test/uri", nil)) {
+		if err == nil {
+			t.Errorf("pages, missing key: returned %v want error", page)
+		}
+		pages++
+	}
+	if pages != 1 {
+		t.Errorf("pages, missing key: yielded %d times want 1", pages)
+	}
+
+	paginator := setupPageClient(2, "items").NewPaginator(setupAuth(), "test/uri", nil)
+	paginator.Key = "items"
+	all, err := Collect[int](paginator)
+	if err != nil || !reflect.DeepEqual(all, []int{1, 1, 2, 2}) || paginator.TotalCount != 4 {
+		t.Errorf("collect envelope: returned %v, %d, %v", all, paginator.TotalCount, err)
+	}
+}
@@ -0,0 +1,150 @@
+package crusch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// UploadOptions are the details of a release asset being uploaded
+// https://docs.github.com/en/rest/releases/assets#upload-a-release-asset
+type UploadOptions struct {
+	// Name is the file name of the asset
+	Name string
+	// Label is an optional short description used in place of the file name
+	Label string
+	// ContentType of the asset, application/octet-stream is used if empty
+	ContentType string
+	// Size of the asset in bytes, Github requires this to be known before uploading
+	// If <= 0 it is taken from seekable readers such as files
+	Size int64
+	// Progress is called as the asset is uploaded with the bytes written so far
+	Progress func(written int64, total int64)
+}
+
+// UploadReleaseAsset uploads the contents of r as an asset of a release
+// uploadURL is the upload_url of the release, its {?name,label} template is removed
+// r is streamed to Github without being buffered into memory
+// The response body (the created asset) will be bound to v
+func (c *Client) UploadReleaseAsset(authorizer Authorizer, uploadURL string, r io.Reader, opts *UploadOptions, v interface{}, options ...RequestOption) (*Response, error) {
+	return c.UploadReleaseAssetContext(context.Background(), authorizer, uploadURL, r, opts, v, options...)
+}
+
+// UploadReleaseAssetContext is UploadReleaseAsset using the given context
+func (c *Client) UploadReleaseAssetContext(ctx context.Context, authorizer Authorizer, uploadURL string, r io.Reader, opts *UploadOptions, v interface{}, options ...RequestOption) (*Response, error) {
+	if opts == nil || opts.Name == "" {
+		return nil, fmt.Errorf("an asset name is required to upload a release asset")
+	}
+
+	size, err := readerSize(r, opts.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("name", opts.Name)
+	if opts.Label != "" {
+		query.Set("label", opts.Label)
+	}
+
+	u := c.expandUploadURL(uploadURL)
+
+	var body io.Reader = r
+	if opts.Progress != nil {
+		body = &progressReader{r: r, total: size, progress: opts.Progress}
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, u, query.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	// http.NewRequest only knows the length of in memory bodies
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	return c.Do(authorizer, req, v, options...)
+}
+
+// expandUploadURL removes the query template from an upload url
+// Relative urls are resolved against the clients upload host
+func (c *Client) expandUploadURL(uploadURL string) string {
+	if i := strings.Index(uploadURL, "{"); i >= 0 {
+		uploadURL = uploadURL[:i]
+	}
+
+	if isAbsolute(uploadURL) {
+		return uploadURL
+	}
+
+	return fmt.Sprintf("%s/%s", c.uploadURL(), strings.TrimLeft(uploadURL, "/"))
+}
+
+// uploadURL returns the url uploads are made to for the client
+// Github serves uploads from uploads.github.com, Github Enterprise Server from /api/uploads
+func (c *Client) uploadURL() string {
+	u := strings.TrimRight(c.URL, "/")
+	switch {
+	case u == "api.github.com":
+		return fmt.Sprintf("%s://uploads.github.com", c.Protocol)
+	case strings.HasSuffix(u, "/api/v3"):
+		return fmt.Sprintf("%s://%s/api/uploads", c.Protocol, strings.TrimSuffix(u, "/api/v3"))
+	default:
+		return fmt.Sprintf("%s://%s", c.Protocol, u)
+	}
+}
+
+// readerSize returns size if it is set, otherwise the remaining size of seekable readers such as files
+func readerSize(r io.Reader, size int64) (int64, error) {
+	if size > 0 {
+		return size, nil
+	}
+
+	f, ok := r.(io.Seeker)
+	if !ok {
+		return 0, fmt.Errorf("size is required when uploading from a reader that is not seekable")
+	}
+
+	current, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get size of reader: %v", err)
+	}
+	end, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get size of reader: %v", err)
+	}
+	_, err = f.Seek(current, io.SeekStart)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get size of reader: %v", err)
+	}
+
+	return end - current, nil
+}
+
+// progressReader reports the number of bytes read from r
+type progressReader struct {
+	r        io.Reader
+	written  int64
+	total    int64
+	progress func(written int64, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.written += int64(n)
+		p.progress(p.written, p.total)
+	}
+	return n, err
+}
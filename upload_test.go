@@ -0,0 +1,95 @@
+package crusch
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUploadReleaseAsset(t *testing.T) {
+	var body string
+	rt := &fakeTransport{handler: func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		body = string(b)
+		respondJSON(w, http.StatusCreated, map[string]string{"name": req.URL.Query().Get("name")})
+	}}
+	client := NewGithubClient("api.github.com", "https")
+	client.SetHTTPClient(&http.Client{Transport: rt})
+
+	content := strings.Repeat("crusch", 1000)
+	var progress []int64
+
+	opts := &UploadOptions{
+		Name:        "crusch linux.tar.gz",
+		Label:       "Linux",
+		ContentType: "application/gzip",
+		Size:        int64(len(content)),
+		Progress: func(written int64, total int64) {
+			if total != int64(len(content)) {
+				t.Errorf("upload progress: total %d want %d", total, len(content))
+			}
+			progress = append(progress, written)
+		},
+	}
+
+	var v map[string]interface{}
+	_, err := client.UploadReleaseAsset(
+		setupAuth(),
+		"https://uploads.github.com/repos/weavc/crusch/releases/1/assets{?name,label}",
+		onlyReader{strings.NewReader(content)},
+		opts,
+		&v)
+	if err != nil {
+		t.Fatalf("upload: unexpected %v", err)
+	}
+
+	req := rt.Request()
+	if u := req.URL.String(); u != "https://uploads.github.com/repos/weavc/crusch/releases/1/assets?label=Linux&name=crusch+linux.tar.gz" {
+		t.Errorf("upload: sent to %s", u)
+	}
+
+	if req.Header.Get("Content-Type") != "application/gzip" || req.ContentLength != int64(len(content)) || body != content {
+		t.Errorf("upload: sent content type %s, length %d", req.Header.Get("Content-Type"), req.ContentLength)
+	}
+
+	if len(progress) == 0 || progress[len(progress)-1] != int64(len(content)) {
+		t.Errorf("upload progress: reported %v", progress)
+	}
+
+	if v["name"] != "crusch linux.tar.gz" {
+		t.Errorf("upload: returned %v", v)
+	}
+
+	_, err = client.UploadReleaseAsset(setupAuth(), "repos/weavc/crusch/releases/1/assets", onlyReader{strings.NewReader(content)}, &UploadOptions{Name: "a"}, nil)
+	if err == nil {
+		t.Errorf("upload unknown size: unexpected nil error")
+	}
+
+	_, err = client.UploadReleaseAsset(setupAuth(), "repos/weavc/crusch/releases/1/assets", strings.NewReader(content), &UploadOptions{Name: "a"}, nil)
+	if err != nil || rt.Request().ContentLength != int64(len(content)) {
+		t.Errorf("upload seekable: returned %v with length %d", err, rt.Request().ContentLength)
+	}
+
+	if u := rt.Request().URL.String(); u != "https://uploads.github.com/repos/weavc/crusch/releases/1/assets?name=a" {
+		t.Errorf("upload relative url: sent to %s", u)
+	}
+}
+
+func TestUploadURL(t *testing.T) {
+	c := NewGithubClient("github.example.com/api/v3", "https")
+	u := c.expandUploadURL("repos/weavc/crusch/releases/1/assets{?name,label}")
+	if u != "https://github.example.com/api/uploads/repos/weavc/crusch/releases/1/assets" {
+		t.Errorf("enterprise upload url: returned %s", u)
+	}
+}
+
+// onlyReader hides any methods other than Read
+type onlyReader struct {
+	r io.Reader
+}
+
+func (r onlyReader) Read(b []byte) (int, error) {
+	return r.r.Read(b)
+}